@@ -17,6 +17,7 @@ package cmd
 import (
 	"github.com/kadmuffin/develbox/cmd/state"
 	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/container"
 	"github.com/kadmuffin/develbox/pkg/podman"
 	"github.com/kpango/glg"
 	"github.com/spf13/cobra"
@@ -35,9 +36,9 @@ var (
 			if err != nil {
 				glg.Fatalf("Can't read config: %s", err)
 			}
-			pman := podman.New(cfg.Podman.Path)
-			if !pman.Exists(cfg.Container.Name) {
-				glg.Fatal("Container does not exist")
+			pman := podman.FromConfig(cfg.Podman)
+			if _, err := container.Open(pman, cfg); err != nil {
+				return err
 			}
 			state.StartContainer(cfg.Container.Name, pman, podman.Attach{})
 			return pman.Attach([]string{cfg.Container.Name}, podman.Attach{Stdin: true, Stdout: true, Stderr: true}).Run()
@@ -0,0 +1,145 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conf contains the commands that check and show the config file
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	resolveFormat string
+
+	// Cmd groups the config commands
+	Cmd = &cobra.Command{
+		Use:   "config",
+		Short: "Checks and shows the config file",
+		Long:  `Checks the config file of the project, prints the config after merging the ones it extends, and prints its JSON Schema.`,
+	}
+
+	// Validate is the cobra command that checks the config file
+	Validate = &cobra.Command{
+		Use:   "validate [file]",
+		Short: "Checks the config file",
+		Long: `Checks the config file (the one of the project if no file is given) and prints every problem with its line and column.
+
+It finds syntax errors, unknown keys, values of the wrong type, ports and mounts that can't be parsed, and package manager templates that are broken or miss a placeholder (like {packages} or {version}).`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			path := config.Path()
+			if len(args) > 0 {
+				path = args[0]
+			}
+
+			problems, err := config.Validate(path)
+			if err != nil {
+				return err
+			}
+
+			asJSON, _ := cmd.Flags().GetBool("json")
+			if err := PrintProblems(os.Stdout, path, problems, asJSON); err != nil {
+				return err
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("found %d problems on %s", len(problems), path)
+			}
+			return nil
+		},
+	}
+
+	// Resolve is the cobra command that prints the effective config
+	Resolve = &cobra.Command{
+		Use:   "resolve",
+		Short: "Prints the config merged with the configs it extends",
+		Long: `Prints the config that develbox uses, after merging the configs on "extends" (files, presets or URLs).
+
+Objects are merged, lists are appended and the rest of the values are replaced by the config that extends them.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			if resolveFormat != "json" && resolveFormat != "yaml" && resolveFormat != "toml" {
+				return fmt.Errorf("unknown format '%s', use json, yaml or toml", resolveFormat)
+			}
+
+			cfg, err := config.Read()
+			if err != nil {
+				return err
+			}
+			data, err := config.Marshal(&cfg, resolveFormat)
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(data)
+			return err
+		},
+	}
+
+	// Schema is the cobra command that prints the JSON Schema of the config
+	Schema = &cobra.Command{
+		Use:   "schema",
+		Short: "Prints the JSON Schema of the config file",
+		Long: `Prints the JSON Schema of the config file, editors can use it for autocompletion.
+
+It's also published at ` + config.SchemaURL + `, add "$schema" with that URL to your config.json.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			schema, err := config.SchemaJSON()
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(schema)
+			return err
+		},
+	}
+)
+
+func init() {
+	Validate.Flags().Bool("json", false, "Print the problems as JSON.")
+	Resolve.Flags().StringVarP(&resolveFormat, "format", "f", "json", "Output format (json, yaml or toml)")
+	Cmd.AddCommand(Validate)
+	Cmd.AddCommand(Resolve)
+	Cmd.AddCommand(Schema)
+}
+
+// PrintProblems writes the problems as "file:line:column: path: message", or as JSON
+func PrintProblems(w io.Writer, path string, problems []config.ValidationError, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(problems)
+	}
+
+	if len(problems) == 0 {
+		fmt.Fprintf(w, "%s is valid.\n", path)
+		return nil
+	}
+	for _, problem := range problems {
+		separator := ":"
+		if problem.Line == 0 {
+			separator = ": "
+		}
+		fmt.Fprintf(w, "%s%s%s\n", path, separator, problem.Error())
+	}
+	return nil
+}
@@ -16,8 +16,10 @@
 package create
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/kadmuffin/develbox/cmd/version"
@@ -36,6 +38,7 @@ var (
 	containerMount string
 	containerPort  string
 	versionTag     string
+	useLockfile    bool
 
 	// Create is the main command for creating a container
 	Create = &cobra.Command{
@@ -83,6 +86,11 @@ var (
 					if v1Cfg {
 						glg.Warn("Config file is from an older version of develbox. Develbox will write an updated version to .develbox/config.json")
 					}
+
+					// The config is written in the same format as the file it comes from
+					if extension := strings.TrimPrefix(filepath.Ext(downloadURL), "."); !configExists && container.Contains(config.Formats, extension) {
+						config.NewFileFormat = extension
+					}
 				}
 
 				config.SetDefaults(&cfg)
@@ -139,14 +147,24 @@ var (
 
 			cfg, err := config.Read()
 			if err != nil {
-				return glg.Errorf("Failed to read .develbox/config.json! Try running 'develbox create -c --force' to create a new one %s.", err)
+				return glg.Errorf("Failed to read %s! Try running 'develbox create -c --force' to create a new one %s.", config.Path(), err)
 			}
 			container.PkgVersion = cmd.Root().Version
+			container.UseLockfile = useLockfile
 			err = container.Create(cfg, forceReplace)
-			if err != nil {
-				return err
+
+			var collision *container.CollisionError
+			if errors.As(err, &collision) {
+				glg.Warnf("The container %s is already used by %s.", collision.Name, collision.ProjectPath)
+
+				cfg.Container.Name = promptRename(collision.Name)
+				if err := config.Write(&cfg); err != nil {
+					return err
+				}
+				container.UseLockfile = useLockfile
+				err = container.Create(cfg, forceReplace)
 			}
-			return nil
+			return err
 		},
 	}
 )
@@ -159,6 +177,7 @@ func init() {
 	Create.Flags().StringVarP(&containerMount, "mount", "m", "none", "The volume to mount in the container.")
 	Create.Flags().StringVarP(&containerPort, "port", "p", "none", "The port to expose in the container.")
 	Create.Flags().StringVarP(&versionTag, "version", "v", version.Number, "The version tag from where the config will be downloaded.")
+	Create.Flags().BoolVar(&useLockfile, "locked", false, "Install the package versions recorded on .develbox/lock.json")
 
 }
 
@@ -174,12 +193,12 @@ func setupGitIgnore() error {
 	return nil
 }
 
-// writeGitignore writes to the .gitignore file ".develbox/home/"
+// writeGitignore writes to the .gitignore file ".develbox/home/", the local config (.develbox/config.local.*) and the locks
 func writeGitIgnore() error {
-	toIgnore := "\n.develbox/home\n"
+	toIgnore := "\n.develbox/home\n.develbox/config.local.*\n.develbox/.lock\n.develbox/.config.lock\n"
 	if !config.FileExists(".gitignore") {
 		os.Create(".gitignore")
-		toIgnore = ".develbox/home\n"
+		toIgnore = ".develbox/home\n.develbox/config.local.*\n.develbox/.lock\n.develbox/.config.lock\n"
 	}
 	f, err := os.OpenFile(".gitignore", os.O_APPEND|os.O_WRONLY, 0600)
 	if err != nil {
@@ -117,6 +117,25 @@ func promptName(cfg *config.Structure) string {
 	return result
 }
 
+// promptRename asks for a new container name when the current one is used by another project. Exits if the user refuses.
+func promptRename(name string) string {
+	prompt := promptui.Prompt{
+		Label: "New container name for this project (empty to cancel)",
+		Validate: func(input string) error {
+			if input == name {
+				return fmt.Errorf("%s is used by another project", name)
+			}
+			return nil
+		},
+	}
+	result, err := prompt.Run()
+	if err != nil || result == "" {
+		glg.Fatalf("Refusing to replace the container of another project.")
+	}
+
+	return result
+}
+
 // promptGitignore prompts to add .develbox/home to .gitignore. Default answer is yes.
 func promptGitignore() bool {
 	prompt := promptui.Prompt{
@@ -151,7 +170,7 @@ func promptEditConfig() {
 			editor = "vi"
 		}
 
-		cmd := exec.Command(editor, ".develbox/config.json")
+		cmd := exec.Command(editor, config.Path())
 		cmd.Stdin = os.Stdin
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
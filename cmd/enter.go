@@ -43,9 +43,12 @@ var (
 			if err != nil {
 				glg.Failf("Can't read config: %s", err)
 			}
-			pman := podman.New(cfg.Podman.Path)
-			if !pman.Exists(cfg.Container.Name) {
-				glg.Fatal("Container does not exist")
+			pman := podman.FromConfig(cfg.Podman)
+			if _, err := container.Open(pman, cfg); err != nil {
+				return err
+			}
+			if err := state.CheckDrift(pman, cfg, true); err != nil {
+				return err
 			}
 			state.StartContainer(cfg.Container.Name, pman, podman.Attach{})
 			if socketExperiment && !root {
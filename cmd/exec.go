@@ -19,8 +19,8 @@ import (
 
 	"github.com/kadmuffin/develbox/cmd/state"
 	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/container"
 	"github.com/kadmuffin/develbox/pkg/podman"
-	"github.com/kpango/glg"
 	"github.com/spf13/cobra"
 )
 
@@ -39,9 +39,12 @@ var (
 			if err != nil {
 				return err
 			}
-			pman := podman.New(cfg.Podman.Path)
-			if !pman.Exists(cfg.Container.Name) {
-				glg.Fatal("Container does not exist")
+			pman := podman.FromConfig(cfg.Podman)
+			if _, err := container.Open(pman, cfg); err != nil {
+				return err
+			}
+			if err := state.CheckDrift(pman, cfg, false); err != nil {
+				return err
 			}
 			state.StartContainer(cfg.Container.Name, pman, podman.Attach{})
 
@@ -0,0 +1,151 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/container"
+	"github.com/kadmuffin/develbox/pkg/podman"
+	"github.com/kpango/glg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	listFormat string
+
+	// List is the cobra command for the list command
+	List = &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ps", "ls"},
+		Short:   "Lists every develbox container on this machine",
+		Long: `Lists every develbox container on this machine (running or not).
+
+The output format can be "table", "json" or a Go template (for example: '{{.Name}} {{.ProjectPath}}').`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			infos, err := container.List(engineForHost())
+			if err != nil {
+				glg.Fatalf("Can't list the containers: %s", err)
+			}
+
+			return PrintList(os.Stdout, infos, listFormat)
+		},
+	}
+)
+
+func init() {
+	List.Flags().StringVarP(&listFormat, "format", "f", "table", "Output format (table, json or a Go template)")
+}
+
+// engineForHost creates the engine using the project config if there's one, otherwise using the default engine
+func engineForHost() podman.Engine {
+	if config.Exists() {
+		cfg, err := config.Read()
+		if err == nil {
+			return podman.FromConfig(cfg.Podman)
+		}
+		glg.Warnf("Can't read config, using the default engine: %s", err)
+	}
+
+	return podman.FromConfig(config.Podman{Path: config.GetContainerTool()})
+}
+
+// PrintList writes the containers using the format (table, json or a Go template)
+func PrintList(w io.Writer, infos []container.Info, format string) error {
+	switch format {
+	case "table", "":
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tPROJECT\tEXISTS\tIMAGE\tSIZE\tVERSION\tLAST USED")
+		for _, info := range infos {
+			exists := "no"
+			if info.ProjectExists {
+				exists = "yes"
+			}
+
+			lastUsed := "running"
+			if !info.Running {
+				lastUsed = humanDuration(time.Since(info.LastUsed)) + " ago"
+			}
+
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", info.Name, info.ProjectPath, exists, info.Image, humanSize(info.Size), info.Version, lastUsed)
+		}
+		return tw.Flush()
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(infos)
+	default:
+		tmpl, err := template.New("format").Parse(format)
+		if err != nil {
+			return err
+		}
+
+		for _, info := range infos {
+			if err := tmpl.Execute(w, info); err != nil {
+				return err
+			}
+			fmt.Fprintln(w)
+		}
+		return nil
+	}
+}
+
+// humanSize returns the size in bytes using the biggest unit that makes sense (for example: 1.5MB)
+func humanSize(size int64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB"}
+
+	value := float64(size)
+	unit := 0
+	for value >= 1000 && unit < len(units)-1 {
+		value /= 1000
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%d%s", size, units[0])
+	}
+	return fmt.Sprintf("%.1f%s", value, units[unit])
+}
+
+// humanDuration returns the duration rounded to its biggest unit (for example: 3 days)
+func humanDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "less than a minute"
+	case d < time.Hour:
+		return plural(int(d.Minutes()), "minute")
+	case d < 24*time.Hour:
+		return plural(int(d.Hours()), "hour")
+	default:
+		return plural(int(d.Hours()/24), "day")
+	}
+}
+
+// plural adds an "s" to the unit if n isn't 1
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -20,6 +20,7 @@ import (
 	"os"
 	"strconv"
 
+	"github.com/kadmuffin/develbox/cmd/conf"
 	"github.com/kadmuffin/develbox/cmd/create"
 	"github.com/kadmuffin/develbox/cmd/dockerfile"
 	"github.com/kadmuffin/develbox/cmd/pkg"
@@ -50,12 +51,40 @@ Created so I don't have to expose my entire computer to random node modules.`,
 	}
 )
 
+func init() {
+	rootCLI.PersistentFlags().StringArrayVar(&config.Sets, "set", []string{}, "Overrides a value of the config for this run (key=value, like container.shell=/bin/bash), can be repeated")
+	rootCLI.PersistentFlags().StringVar(&config.ProfileName, "profile", "", "Uses a profile of the config (like ci or gui), DEVELBOX_PROFILE is used if it isn't set")
+}
+
 // Execute is the entrypoint for the program
 func Execute() error {
 	if os.Getuid() == 0 && !podman.InsideContainer() {
 		glg.Fatal("Develbox doesn't currently support being ran as root.")
 	}
 
+	return ExecuteArgs(os.Args[1:])
+}
+
+// ExecuteArgs runs the command line on args (without the program name).
+//
+// The root flags before the subcommand are parsed first, so the early config read sees them and the
+// commands with DisableFlagParsing don't get them as arguments (see pkg.ParseRootFlags).
+func ExecuteArgs(args []string) error {
+	args, err := pkg.ParseRootFlags(rootCLI, args, true)
+	if err != nil {
+		rootCLI.PrintErrln("Error:", err)
+		return err
+	}
+
+	if !rootCLI.HasSubCommands() {
+		addCommands()
+	}
+	rootCLI.SetArgs(args)
+	return rootCLI.Execute()
+}
+
+// addCommands adds the subcommands that can be used where develbox runs (inside or outside the container)
+func addCommands() {
 	// Package manager operations are added if:
 	// - The user is outside the container
 	// - The user is inside the container and the socket experiment is enabled
@@ -67,6 +96,7 @@ func Execute() error {
 		rootCLI.AddCommand(pkg.Update)
 		rootCLI.AddCommand(pkg.Upgrade)
 		rootCLI.AddCommand(pkg.Search)
+		rootCLI.AddCommand(pkg.Cmd)
 	}
 
 	if !podman.InsideContainer() {
@@ -93,11 +123,12 @@ func Execute() error {
 		rootCLI.AddCommand(state.Stop)
 		rootCLI.AddCommand(state.Restart)
 		rootCLI.AddCommand(state.Trash)
+		rootCLI.AddCommand(List)
+		rootCLI.AddCommand(Prune)
 	}
+	rootCLI.AddCommand(conf.Cmd)
 	rootCLI.AddCommand(version.VersionCmd)
 	rootCLI.AddCommand(dockerfile.Build)
-
-	return rootCLI.Execute()
 }
 
 // GetRootCLI returns the root command for the program
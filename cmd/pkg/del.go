@@ -33,6 +33,12 @@ var (
 		Long:               "Deletes packages using the package manager defined in the config.",
 		DisableFlagParsing: true,
 		Run: func(cmd *cobra.Command, args []string) {
+			args, err := ParseRootFlags(cmd.Root(), args, false)
+			if err != nil {
+				glg.Error(err)
+				return
+			}
+			args, manager := parseWith(args)
 			packages, flags := pkgm.ParseArguments(args)
 			parsedFlags := parseFlags(&flags)
 
@@ -50,6 +56,10 @@ var (
 				glg.Error(err)
 				return
 			}
+			if err := opertn.SetManager(&cfg, manager); err != nil {
+				glg.Error(err)
+				return
+			}
 
 			StartContainer(&cfg)
 
@@ -58,13 +68,7 @@ var (
 				return
 			}
 
-			opertn.Process(&cfg)
-			if err != nil {
-				glg.Error(err)
-				return
-			}
-			err = config.Write(&cfg)
-			if err != nil {
+			if err := opertn.Apply(&cfg); err != nil {
 				glg.Error(err)
 			}
 		},
@@ -72,6 +76,7 @@ var (
 )
 
 func init() {
+	Del.Flags().String("with", "", "Use one of the package managers on image.pkgmanagers (for example, pip).")
 	Del.Flags().BoolP("user", "U", false, "Install packages as user instead of root.")
 	Del.Flags().BoolP("pkg-help", "p", false, "Show the package manager help for this command.")
 
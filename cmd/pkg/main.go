@@ -20,14 +20,17 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/kadmuffin/develbox/cmd/state"
 	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/container"
 	"github.com/kadmuffin/develbox/pkg/pkgm"
 	"github.com/kadmuffin/develbox/pkg/podman"
 	"github.com/kadmuffin/develbox/pkg/socket"
 	"github.com/kpango/glg"
+	"github.com/spf13/cobra"
 )
 
 // Flags is a struct to hold CLI flags
@@ -65,6 +68,68 @@ func parseFlags(flags *[]string) (result Flags) {
 	return
 }
 
+// parseWith removes "--with <name>" (or "--with=<name>") from the arguments, returning the name of the package manager.
+// There's no short flag, the flags of the package manager (like pacman's -w) are passed to it.
+func parseWith(args []string) (rest []string, manager string) {
+	rest = []string{}
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--with" && i+1 < len(args):
+			manager = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--with="):
+			manager = strings.TrimPrefix(args[i], "--with=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return rest, manager
+}
+
+// RootFlags are the flags of the root command that take a value. The commands with DisableFlagParsing get them
+// as arguments, so they are taken out with ParseRootFlags.
+var RootFlags = []string{"--set", "--profile"}
+
+// ParseRootFlags sets the RootFlags found on args ("--set k=v" or "--set=k=v") on the root command and returns the rest.
+// With leading, only the flags before the first argument (the subcommand) are parsed.
+func ParseRootFlags(root *cobra.Command, args []string, leading bool) ([]string, error) {
+	rest := []string{}
+	for i := 0; i < len(args); i++ {
+		if leading && !strings.HasPrefix(args[i], "-") {
+			return append(rest, args[i:]...), nil
+		}
+
+		name, value, found := rootFlag(args[i])
+		switch {
+		case name == "":
+			rest = append(rest, args[i])
+			continue
+		case !found && i+1 >= len(args):
+			return nil, fmt.Errorf("flag needs an argument: %s", name)
+		case !found:
+			value = args[i+1]
+			i++
+		}
+		if err := root.PersistentFlags().Set(strings.TrimPrefix(name, "--"), value); err != nil {
+			return nil, fmt.Errorf("invalid argument %q for %s: %s", value, name, err)
+		}
+	}
+	return rest, nil
+}
+
+// rootFlag returns the name of the root flag on arg, and its value when it's written as --flag=value
+func rootFlag(arg string) (name, value string, found bool) {
+	for _, flag := range RootFlags {
+		if arg == flag {
+			return flag, "", false
+		}
+		if strings.HasPrefix(arg, flag+"=") {
+			return flag, strings.TrimPrefix(arg, flag+"="), true
+		}
+	}
+	return "", "", false
+}
+
 // SendOperation sends an operation to the socket server
 func SendOperation(opertn pkgm.Operation) {
 
@@ -103,9 +168,9 @@ func SendOperation(opertn pkgm.Operation) {
 // StartContainer starts the container, if we are not inside it.
 func StartContainer(cfg *config.Structure) {
 	if !podman.InsideContainer() {
-		pman := podman.New(cfg.Podman.Path)
-		if !pman.Exists(cfg.Container.Name) {
-			glg.Fatal("Container does not exist")
+		pman := podman.FromConfig(cfg.Podman)
+		if _, err := container.Open(pman, *cfg); err != nil {
+			glg.Fatal(err)
 		}
 
 		state.StartContainer(cfg.Container.Name, pman, podman.Attach{})
@@ -15,25 +15,41 @@
 package pkg
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/kadmuffin/develbox/pkg/config"
 	"github.com/kadmuffin/develbox/pkg/pkgm"
 	"github.com/kadmuffin/develbox/pkg/podman"
 	"github.com/kpango/glg"
+	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 )
 
 var (
 	// Search is the cobra command for searching for packages
 	Search = &cobra.Command{
-		Use:                "search",
-		Aliases:            []string{"srch", "sh"},
-		Short:              "Search for packages using the pkg manager",
-		Long:               "Search for (all, usually) matching packages using the package manager defined in the config.",
+		Use:     "search",
+		Aliases: []string{"srch", "sh"},
+		Short:   "Search for packages using the pkg manager",
+		Long: `Search for (all, usually) matching packages using the package manager defined in the config.
+
+The results are shown as a table, use --json to print them as JSON, --pick to choose which ones to install or --raw to see the output of the package manager.`,
 		DisableFlagParsing: true,
 		Run: func(cmd *cobra.Command, args []string) {
+			args, err := ParseRootFlags(cmd.Root(), args, false)
+			if err != nil {
+				glg.Error(err)
+				return
+			}
+			args, manager := parseWith(args)
 			packages, flags := pkgm.ParseArguments(args)
+			flags, format, pick := parseSearchFlags(flags)
 			parsedFlags := parseFlags(&flags)
 
 			if parsedFlags.ShowHelp || len(packages)+len(parsedFlags.All) == 0 {
@@ -48,6 +64,10 @@ var (
 				glg.Error(err)
 				return
 			}
+			if err := opertn.SetManager(&cfg, manager); err != nil {
+				glg.Error(err)
+				return
+			}
 
 			StartContainer(&cfg)
 
@@ -56,13 +76,26 @@ var (
 				return
 			}
 
-			opertn.Process(&cfg)
-			if err != nil {
-				glg.Error(err)
-				return
+			if format != "raw" {
+				results, err := pkgm.Search(&cfg, opertn)
+				switch {
+				case errors.Is(err, pkgm.ErrNoSearchParser):
+					glg.Debug(err)
+				case err != nil:
+					glg.Error(err)
+					return
+				case pick:
+					installPicked(&cfg, results, parsedFlags, opertn.Manager)
+					return
+				default:
+					if err := PrintSearch(os.Stdout, results, format); err != nil {
+						glg.Error(err)
+					}
+					return
+				}
 			}
-			err = config.Write(&cfg)
-			if err != nil {
+
+			if err := opertn.Apply(&cfg); err != nil {
 				glg.Error(err)
 			}
 		},
@@ -70,5 +103,116 @@ var (
 )
 
 func init() {
+	Search.Flags().String("with", "", "Use one of the package managers on image.pkgmanagers (for example, pip).")
 	Search.Flags().BoolP("pkg-help", "p", false, "Show the package manager help for this command.")
+	Search.Flags().Bool("json", false, "Print the results as JSON.")
+	Search.Flags().Bool("raw", false, "Show the output of the package manager.")
+	Search.Flags().BoolP("pick", "i", false, "Choose which packages to install.")
+}
+
+// parseSearchFlags removes the flags used by the search command from the ones passed to the package manager
+func parseSearchFlags(flags []string) (rest []string, format string, pick bool) {
+	format = "table"
+	for _, flag := range flags {
+		switch flag {
+		case "--json":
+			format = "json"
+		case "--raw":
+			format = "raw"
+		case "--pick", "-i":
+			pick = true
+		default:
+			rest = append(rest, flag)
+		}
+	}
+	return rest, format, pick
+}
+
+// PrintSearch writes the search results as a table or as JSON
+func PrintSearch(w io.Writer, results []pkgm.SearchResult, format string) error {
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	}
+
+	if len(results) == 0 {
+		fmt.Fprintln(w, "No packages found.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tVERSION\tINSTALLED\tDESCRIPTION")
+	for _, result := range results {
+		installed := ""
+		if result.Installed {
+			installed = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.Name, result.Version, installed, result.Description)
+	}
+	return tw.Flush()
+}
+
+// installPicked lets the user choose packages from the results and installs them
+func installPicked(cfg *config.Structure, results []pkgm.SearchResult, flags Flags, manager string) {
+	picked := pickPackages(results, map[string]bool{})
+	if len(picked) == 0 {
+		fmt.Println("Nothing was installed.")
+		return
+	}
+
+	opertn := pkgm.NewOperation("add", picked, []string{}, false)
+	opertn.UserOperation = flags.UserOpert
+	opertn.DevInstall = flags.DevPkg
+	if err := opertn.SetManager(cfg, manager); err != nil {
+		glg.Error(err)
+		return
+	}
+
+	if err := opertn.Apply(cfg); err != nil {
+		glg.Error(err)
+	}
+}
+
+// pickPackages is a prompt that toggles packages until the user selects "Install"
+func pickPackages(results []pkgm.SearchResult, selected map[string]bool) []string {
+	items := []string{fmt.Sprintf("Install the selected packages (%d)", len(selected))}
+	for _, result := range results {
+		mark := "[ ]"
+		if selected[result.Name] {
+			mark = "[x]"
+		}
+		if result.Installed {
+			mark = "[i]"
+		}
+		items = append(items, strings.TrimSpace(fmt.Sprintf("%s %s %s - %s", mark, result.Name, result.Version, result.Description)))
+	}
+
+	prompt := promptui.Select{
+		Label: "Select the packages to install",
+		Items: items,
+		Size:  15,
+		Searcher: func(input string, index int) bool {
+			return strings.Contains(strings.ToLower(items[index]), strings.ToLower(input))
+		},
+	}
+
+	index, _, err := prompt.Run()
+	if err != nil {
+		return []string{}
+	}
+
+	if index > 0 {
+		name := results[index-1].Name
+		selected[name] = !selected[name]
+		return pickPackages(results, selected)
+	}
+
+	picked := []string{}
+	for _, result := range results {
+		if selected[result.Name] {
+			picked = append(picked, result.Name)
+		}
+	}
+	return picked
 }
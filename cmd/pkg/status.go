@@ -0,0 +1,139 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/pkgm"
+	"github.com/kpango/glg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statusFormat string
+
+	// Cmd groups the package commands that don't change the container
+	Cmd = &cobra.Command{
+		Use:   "pkg",
+		Short: "Shows the packages of the container",
+		Long:  `Compares the packages on the config with the ones installed in the container.`,
+	}
+
+	// List is the cobra command that shows the declared and installed packages
+	List = &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "Lists the declared and installed packages",
+		Long: `Lists the packages on the config next to the version installed in the container.
+
+Packages that are declared but not installed are shown as "missing", and packages that were installed but aren't on the config as "undeclared".`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return printStatus(cmd, false)
+		},
+	}
+
+	// Outdated is the cobra command that shows the packages with updates
+	Outdated = &cobra.Command{
+		Use:   "outdated",
+		Short: "Lists the packages that have updates available",
+		Long:  `Lists the installed packages that have a newer version available, next to the version that is installed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return printStatus(cmd, true)
+		},
+	}
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{List, Outdated} {
+		cmd.Flags().StringVarP(&statusFormat, "format", "f", "table", "Output format (table or json)")
+		cmd.Flags().Bool("json", false, "Print the packages as JSON.")
+	}
+	Cmd.AddCommand(List)
+	Cmd.AddCommand(Outdated)
+}
+
+// printStatus queries the container and prints the packages (only the ones with updates if outdated is true)
+func printStatus(cmd *cobra.Command, outdated bool) error {
+	cfg, err := config.Read()
+	if err != nil {
+		glg.Fatalf("Can't read config file: %s", err)
+	}
+	StartContainer(&cfg)
+
+	statuses, err := pkgm.Status(&cfg)
+	if err != nil {
+		return err
+	}
+
+	if outdated {
+		filtered := []pkgm.PackageStatus{}
+		for _, status := range statuses {
+			if status.Candidate != "" {
+				filtered = append(filtered, status)
+			}
+		}
+		statuses = filtered
+	}
+
+	format := statusFormat
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		format = "json"
+	}
+	return PrintStatus(os.Stdout, statuses, format)
+}
+
+// PrintStatus writes the packages as a table or as JSON
+func PrintStatus(w io.Writer, statuses []pkgm.PackageStatus, format string) error {
+	switch format {
+	case "table", "":
+		if len(statuses) == 0 {
+			fmt.Fprintln(w, "No packages found.")
+			return nil
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tMANAGER\tSECTION\tSTATUS\tINSTALLED\tCANDIDATE")
+		for _, status := range statuses {
+			manager := status.Manager
+			if manager == "" {
+				manager = config.SystemPkgManager
+			}
+
+			state := "installed"
+			switch {
+			case status.Section == "":
+				state = "undeclared"
+			case !status.Installed:
+				state = "missing"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", status.Name, manager, status.Section, state, status.Version, status.Candidate)
+		}
+		return tw.Flush()
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(statuses)
+	default:
+		return fmt.Errorf("unknown format '%s', use table or json", format)
+	}
+}
@@ -36,6 +36,12 @@ var (
 		`,
 		DisableFlagParsing: true,
 		Run: func(cmd *cobra.Command, args []string) {
+			args, err := ParseRootFlags(cmd.Root(), args, false)
+			if err != nil {
+				glg.Error(err)
+				return
+			}
+			args, manager := parseWith(args)
 			packages, flags := pkgm.ParseArguments(args)
 			parsedFlags := parseFlags(&flags)
 
@@ -52,6 +58,10 @@ var (
 				glg.Error(err)
 				return
 			}
+			if err := opertn.SetManager(&cfg, manager); err != nil {
+				glg.Error(err)
+				return
+			}
 			StartContainer(&cfg)
 
 			if podman.InsideContainer() && os.Getuid() != 0 {
@@ -59,13 +69,7 @@ var (
 				return
 			}
 
-			opertn.Process(&cfg)
-			if err != nil {
-				glg.Error(err)
-				return
-			}
-			err = config.Write(&cfg)
-			if err != nil {
+			if err := opertn.Apply(&cfg); err != nil {
 				glg.Error(err)
 			}
 		},
@@ -73,6 +77,7 @@ var (
 )
 
 func init() {
+	Update.Flags().String("with", "", "Use one of the package managers on image.pkgmanagers (for example, pip).")
 	Update.Flags().BoolP("user", "U", false, "Install packages as user instead of root.")
 	Update.Flags().BoolP("pkg-help", "p", false, "Show the package manager help for this command.")
 
@@ -33,6 +33,12 @@ var (
 		Long:               "Upgrades (all, usually) packages using the package manager defined in the config.",
 		DisableFlagParsing: true,
 		Run: func(cmd *cobra.Command, args []string) {
+			args, err := ParseRootFlags(cmd.Root(), args, false)
+			if err != nil {
+				glg.Error(err)
+				return
+			}
+			args, manager := parseWith(args)
 			packages, flags := pkgm.ParseArguments(args)
 			parsedFlags := parseFlags(&flags)
 
@@ -49,6 +55,10 @@ var (
 				glg.Error(err)
 				return
 			}
+			if err := opertn.SetManager(&cfg, manager); err != nil {
+				glg.Error(err)
+				return
+			}
 
 			StartContainer(&cfg)
 
@@ -57,13 +67,7 @@ var (
 				return
 			}
 
-			opertn.Process(&cfg)
-			if err != nil {
-				glg.Error(err)
-				return
-			}
-			err = config.Write(&cfg)
-			if err != nil {
+			if err := opertn.Apply(&cfg); err != nil {
 				glg.Error(err)
 			}
 		},
@@ -71,6 +75,7 @@ var (
 )
 
 func init() {
+	Upgrade.Flags().String("with", "", "Use one of the package managers on image.pkgmanagers (for example, pip).")
 	Upgrade.Flags().BoolP("user", "U", false, "Install packages as user instead of root.")
 	Upgrade.Flags().BoolP("pkg-help", "p", false, "Show the package manager help for this command.")
 
@@ -0,0 +1,104 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/kadmuffin/develbox/pkg/container"
+	"github.com/kpango/glg"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneDryRun bool
+	pruneYes    bool
+	pruneShared bool
+
+	// Prune is the cobra command for the prune command
+	Prune = &cobra.Command{
+		Use:   "prune",
+		Short: "Removes the containers, images and shared folders of deleted projects",
+		Long: `Removes what develbox left behind:
+  - Containers whose project folder doesn't exist anymore
+  - Images commited by auto_commit for projects that don't exist anymore
+  - Shared folders ($XDG_DATA_HOME/develbox/shared) that no container or project uses (only with --shared)`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			pman := engineForHost()
+			orphans, err := container.FindOrphans(pman, pruneShared)
+			if err != nil {
+				glg.Fatalf("Can't search for orphans: %s", err)
+			}
+
+			if len(orphans) == 0 {
+				fmt.Println("Nothing to prune.")
+				return nil
+			}
+
+			total := PrintOrphans(os.Stdout, orphans)
+			if pruneDryRun {
+				fmt.Printf("Would reclaim %s (dry run, nothing was removed).\n", humanSize(total))
+				return nil
+			}
+
+			if !pruneYes && !promptPrune() {
+				fmt.Println("Nothing was removed.")
+				return nil
+			}
+
+			reclaimed, err := container.RemoveOrphans(pman, orphans)
+			fmt.Printf("Reclaimed %s.\n", humanSize(reclaimed))
+			return err
+		},
+	}
+)
+
+func init() {
+	Prune.Flags().BoolVarP(&pruneDryRun, "dry-run", "n", false, "Only print what would be removed")
+	Prune.Flags().BoolVarP(&pruneYes, "yes", "y", false, "Don't ask for confirmation")
+	Prune.Flags().BoolVar(&pruneShared, "shared", false, "Also remove the shared folders that aren't used")
+}
+
+// PrintOrphans writes the orphans as a table and returns their total size
+func PrintOrphans(w io.Writer, orphans []container.Orphan) int64 {
+	var total int64
+
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tREF\tSIZE\tREASON")
+	for _, orphan := range orphans {
+		total += orphan.Size
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", orphan.Kind, orphan.Ref, humanSize(orphan.Size), orphan.Reason)
+	}
+	tw.Flush()
+
+	return total
+}
+
+// promptPrune asks the user to confirm the removal. Default answer is no.
+func promptPrune() bool {
+	prompt := promptui.Prompt{
+		Label:     "Remove them",
+		IsConfirm: true,
+	}
+	result, _ := prompt.Run()
+
+	return result == "y" || result == "Y"
+}
@@ -15,12 +15,15 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
+	"os"
 	"regexp"
 	"strings"
 
 	"github.com/kadmuffin/develbox/cmd/state"
 	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/container"
 	"github.com/kadmuffin/develbox/pkg/podman"
 	"github.com/kpango/glg"
 	"github.com/spf13/cobra"
@@ -28,7 +31,7 @@ import (
 
 var (
 	cfg  config.Structure
-	pman podman.Podman
+	pman podman.Engine
 
 	// Run is the command to run command defined in config.
 	Run = &cobra.Command{
@@ -46,9 +49,12 @@ var (
 				return err
 			}
 
-			pman = podman.New(cfg.Podman.Path)
-			if !pman.Exists(cfg.Container.Name) {
-				glg.Fatal("Container does not exist")
+			pman = podman.FromConfig(cfg.Podman)
+			if _, err := container.Open(pman, cfg); err != nil {
+				return err
+			}
+			if err := state.CheckDrift(pman, cfg, false); err != nil {
+				return err
 			}
 			state.StartContainer(cfg.Container.Name, pman, podman.Attach{})
 
@@ -160,8 +166,7 @@ func parseSubBash(v string) (string, error) {
 	matches := re.FindAllStringSubmatch(v, -1)
 
 	for _, match := range matches {
-		params := []string{cfg.Container.Name, match[1]}
-		result, err := pman.Exec(params, cfg.Image.Variables, true, false, podman.Attach{}).Output()
+		result, err := execOutput(match[1], false)
 		if err != nil {
 			return "", err
 		}
@@ -182,8 +187,7 @@ func parseSubRootBash(v string) (string, error) {
 	matches := re.FindAllStringSubmatch(v, -1)
 
 	for _, match := range matches {
-		params := []string{cfg.Container.Name, match[1]}
-		result, err := pman.Exec(params, cfg.Image.Variables, true, true, podman.Attach{}).Output()
+		result, err := execOutput(match[1], true)
 		if err != nil {
 			return "", err
 		}
@@ -194,6 +198,26 @@ func parseSubRootBash(v string) (string, error) {
 	return v, nil
 }
 
+// execOutput runs a command inside the container and returns its output. Uses the API when the engine supports it.
+func execOutput(command string, root bool) ([]byte, error) {
+	streamer, ok := pman.(podman.Streamer)
+	if !ok {
+		params := []string{cfg.Container.Name, command}
+		return pman.Exec(params, cfg.Image.Variables, true, root, podman.Attach{}).Output()
+	}
+
+	var stdout bytes.Buffer
+	opts := podman.NewExecOptions([]string{command}, cfg.Image.Variables, true, root)
+	code, err := streamer.ExecStream(cfg.Container.Name, opts, nil, &stdout, os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if code != 0 {
+		return nil, fmt.Errorf("exit status %d", code)
+	}
+	return stdout.Bytes(), nil
+}
+
 // runBashParse runs the bash parse and returns the result.
 func runBashParse(v string) (string, error) {
 	v, err := parseSubBash(v)
@@ -19,6 +19,7 @@ import (
 
 	"github.com/kadmuffin/develbox/cmd/state"
 	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/container"
 	"github.com/kadmuffin/develbox/pkg/pkgm"
 	"github.com/kadmuffin/develbox/pkg/podman"
 	"github.com/kadmuffin/develbox/pkg/socket"
@@ -39,9 +40,9 @@ var (
 			if err != nil {
 				glg.Failf("Can't read config: %s", err)
 			}
-			pman := podman.New(cfg.Podman.Path)
-			if !pman.Exists(cfg.Container.Name) {
-				glg.Fatal("Container does not exist")
+			pman := podman.FromConfig(cfg.Podman)
+			if _, err := container.Open(pman, cfg); err != nil {
+				glg.Fatal(err)
 			}
 			state.StartContainer(cfg.Container.Name, pman, podman.Attach{})
 
@@ -83,6 +84,15 @@ func createSocket(cfg *config.Structure) {
 
 			// Print the operation as JSON text
 			glg.Debug(operation.ToJSON())
+
+			// The CLI could be changing the config at the same time
+			lock, err := config.LockProject()
+			if err != nil {
+				glg.Error(err)
+				return
+			}
+			defer lock.Unlock()
+
 			command, err := operation.ProcessCmd(cfg, podman.Attach{})
 			if err != nil {
 				glg.Fatal(err)
@@ -99,8 +109,9 @@ func createSocket(cfg *config.Structure) {
 			glg.Debug("Running command: ", command)
 			err = command.Run()
 			if err == nil {
-				operation.UpdateConfig(cfg)
-				config.Write(cfg)
+				if err := operation.Commit(cfg); err != nil {
+					glg.Error(err)
+				}
 			}
 
 			glg.Debug("Command finished with error: %v\n", err)
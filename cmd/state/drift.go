@@ -0,0 +1,64 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kadmuffin/develbox/cmd/version"
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/container"
+	"github.com/kadmuffin/develbox/pkg/podman"
+	"github.com/kpango/glg"
+	"github.com/manifoldco/promptui"
+)
+
+// CheckDrift warns if the config changed since the container was created. If offer is true, it asks to recreate the container.
+func CheckDrift(pman podman.Engine, cfg config.Structure, offer bool) error {
+	info, err := pman.Inspect(cfg.Container.Name)
+	if err != nil {
+		return nil
+	}
+
+	fields, known := container.Drift(info, cfg)
+	if !known {
+		glg.Info("The container was created by an older version, config changes can't be detected.")
+		return nil
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	glg.Warnf("The container doesn't match the config anymore, changed fields: %s", strings.Join(fields, ", "))
+	if !offer || !promptRecreate() {
+		fmt.Println("Run 'develbox create -f' to recreate the container.")
+		return nil
+	}
+
+	container.PkgVersion = version.Number
+	return container.Create(cfg, true)
+}
+
+// promptRecreate asks the user if the container should be recreated. Default answer is no.
+func promptRecreate() bool {
+	prompt := promptui.Prompt{
+		Label:     "Recreate the container",
+		IsConfirm: true,
+	}
+	result, _ := prompt.Run()
+
+	return result == "y" || result == "Y"
+}
@@ -18,8 +18,10 @@ package state
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/container"
 	"github.com/kadmuffin/develbox/pkg/podman"
 	"github.com/kpango/glg"
 	"github.com/spf13/cobra"
@@ -37,19 +39,21 @@ var Cmd = &cobra.Command{
 			glg.Fatalf("Can't read config file: %s", err)
 		}
 
-		pman := podman.New(cfg.Podman.Path)
-
-		if !pman.Exists(cfg.Container.Name) {
+		pman := podman.FromConfig(cfg.Podman)
+		info, err := container.Open(pman, cfg)
+		if err == container.ErrNoContainer {
 			fmt.Println("Container does not exist.")
 			os.Exit(1)
 		}
+		if err != nil {
+			glg.Fatal(err)
+		}
 
-		running := pman.IsRunning(cfg.Container.Name)
-		if running {
-			fmt.Println("Container is running!")
+		if info.State.Running {
+			fmt.Printf("Container is running! (since %s)\n", info.State.StartedAt.Local().Format(time.RFC1123))
 			os.Exit(0)
 		} else {
-			fmt.Println("Container exists but is not running!")
+			fmt.Printf("Container exists but is not running! (status: %s, exit code: %d)\n", info.State.Status, info.State.ExitCode)
 			os.Exit(2)
 		}
 	},
@@ -16,8 +16,6 @@ package state
 
 import (
 	"fmt"
-	"regexp"
-	"strings"
 
 	"github.com/kadmuffin/develbox/pkg/config"
 	"github.com/kadmuffin/develbox/pkg/container"
@@ -38,10 +36,12 @@ var (
 			if err != nil {
 				glg.Fatal(err)
 			}
-			pman := podman.New(cfg.Podman.Path)
-
-			if !pman.Exists(cfg.Container.Name) {
-				glg.Fatal("Container does not exist")
+			pman := podman.FromConfig(cfg.Podman)
+			if _, err := container.Open(pman, cfg); err != nil {
+				glg.Fatal(err)
+			}
+			if err := CheckDrift(pman, cfg, true); err != nil {
+				glg.Fatal(err)
 			}
 
 			err = StartContainer(cfg.Container.Name, pman, podman.Attach{})
@@ -54,7 +54,7 @@ var (
 )
 
 // StartContainer starts the container
-func StartContainer(name string, pman podman.Podman, attach podman.Attach) error {
+func StartContainer(name string, pman podman.Engine, attach podman.Attach) error {
 	err := SearchActiveContainers(name, pman, attach)
 	if err != nil {
 		return err
@@ -68,7 +68,7 @@ func StartContainer(name string, pman podman.Podman, attach podman.Attach) error
 }
 
 // SearchActiveContainers searches for all containers that are running and asks user to choose which ones to stop
-func SearchActiveContainers(name string, pman podman.Podman, attach podman.Attach) error {
+func SearchActiveContainers(name string, pman podman.Engine, attach podman.Attach) error {
 	containers, err := SearchActiveContainer(pman)
 	if err != nil {
 		glg.Warn(err)
@@ -112,7 +112,7 @@ func SearchActiveContainers(name string, pman podman.Podman, attach podman.Attac
 
 // chooseWhatToStop is a prompt that allows the user to choose what to stop
 // It's a recursive function that doesn't exit until the user selects "Done"
-func chooseWhatToStop(pman podman.Podman, containers []ContInfo) error {
+func chooseWhatToStop(pman podman.Engine, containers []ContInfo) error {
 	contString := make([]string, len(containers))
 	for i, container := range containers {
 		contString[i] = container.String()
@@ -184,71 +184,23 @@ func (e *ContInfo) String() string {
 // SearchActiveContainer searches for all active containers and returns id, name, and project path of containers that match
 // Mainly, it searches containers with the label develbox_container=1
 // For the project path it gets the label develbox_project_path
-func SearchActiveContainer(pman podman.Podman) ([]ContInfo, error) {
-	cmd := pman.RawCommand([]string{"ps", "--format", "{{.ID}}\t{{.Names}}\t{{.Labels}}"}, podman.Attach{
-		Stderr: true,
-	})
-	containers, err := cmd.Output()
+func SearchActiveContainer(pman podman.Engine) ([]ContInfo, error) {
+	containers, err := pman.List(false, []string{"develbox_container=1"})
 	if err != nil {
 		return []ContInfo{}, err
 	}
 
-	// Split the output into lines
-	lines := strings.Split(string(containers), "\n")
-
 	// Create a slice to store the containers
 	var activeContainers []ContInfo
 
-	// Loop through the lines
-	for _, line := range lines {
-		// Split the line into fields
-		fields := strings.Split(line, "\t")
-
-		if container.ContainsString(fields, "develbox") {
-			ppath, err := parseFromField(fields[2], "develbox_project_path")
-			if err != nil {
-				glg.Info(err)
-			}
-
-			dboxVersion, err := parseFromField(fields[2], "develbox_version")
-			if err != nil {
-				glg.Info(err)
-			}
-			activeContainers = append(activeContainers, ContInfo{
-				Name:        fields[1],
-				ID:          fields[0],
-				ProjectPath: ppath,
-				DBoxVersion: dboxVersion,
-			})
-		}
+	for _, info := range containers {
+		activeContainers = append(activeContainers, ContInfo{
+			Name:        info.Name,
+			ID:          info.ID,
+			ProjectPath: info.Label("develbox_project_path"),
+			DBoxVersion: info.Label("develbox_version"),
+		})
 	}
 
 	return activeContainers, nil
 }
-
-// keyval parses a string like this:
-// "develbox_project_path=/home/username/project,develbox_container=1,develbox_version=0.1.0"
-// and return the value of the key
-var keyval = regexp.MustCompile(`\s*([^\s,:=]*)\s*[:=]\s*([^\s,:=]*)\s*`)
-
-// parseFromField parses a field from a podman ps --format
-func parseFromField(field string, key string) (string, error) {
-	// Remove the curly braces
-	field = strings.Trim(field, "{}")
-	// Split the field into key value pairs
-	pairs := strings.Split(field, ",")
-	// Loop through the pairs
-	for _, pair := range pairs {
-		// Check if the key matches
-		if strings.Contains(pair, key) {
-			// Split the pair into key and value
-			split := keyval.FindStringSubmatch(pair)
-			if len(split) != 3 {
-				return "", fmt.Errorf("field %q does not contain key %q", field, key)
-			}
-			// Return the value
-			return split[2], nil
-		}
-	}
-	return "", fmt.Errorf("field %q does not contain key %q", field, key)
-}
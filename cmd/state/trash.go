@@ -16,6 +16,7 @@ package state
 
 import (
 	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/container"
 	"github.com/kadmuffin/develbox/pkg/podman"
 	"github.com/kpango/glg"
 	"github.com/spf13/cobra"
@@ -32,10 +33,9 @@ var (
 			if err != nil {
 				glg.Fatal(err)
 			}
-			pman := podman.New(cfg.Podman.Path)
-
-			if !pman.Exists(cfg.Container.Name) {
-				glg.Fatal("Container does not exist")
+			pman := podman.FromConfig(cfg.Podman)
+			if _, err := container.Open(pman, cfg); err != nil {
+				glg.Fatal(err)
 			}
 
 			err = pman.Remove([]string{cfg.Container.Name}, podman.Attach{Stderr: true})
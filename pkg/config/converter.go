@@ -71,6 +71,10 @@ func ConvertFromV1(cfg *v1config.Struct) Structure {
 		Experiments: cfg.Podman.Container.Experiments,
 	}
 
+	// v1 always filled the name with the folder name hash, drop it so the path based default is used
+	if newCfg.Container.Name == LegacyName() {
+		newCfg.Container.Name = ""
+	}
 	SetName(&newCfg)
 
 	glg.Info("Converted config file to v2 format")
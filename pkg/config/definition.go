@@ -17,6 +17,7 @@ package config
 import (
 	"fmt"
 	"os/exec"
+	"sort"
 
 	"github.com/creasty/defaults"
 	v1config "github.com/kadmuffin/develbox/pkg/config/v1config"
@@ -42,17 +43,47 @@ type Operations struct {
 
 	// Clean is the command to clean the cache, necessary if we want to reduce the image size (using the build command)
 	Clean string `default:"" json:"clean"`
+
+	// Query is the base string that prints the installed version of packages, one "<name> <version>" per line (used for the lock file)
+	Query string `default:"" json:"query"`
+
+	// Installed prints the packages that were explicitly installed, one per line (used by "develbox pkg list")
+	Installed string `default:"" json:"installed"`
+
+	// Outdated prints the packages that have an update, one "<name> <candidate version>" per line (used by "develbox pkg outdated")
+	Outdated string `default:"" json:"outdated"`
 }
 
+// DriverNames are the accepted values of PackageManager.Driver, pkgm sets them to its drivers and "custom".
+// Used by the schema and the validation, they don't check the driver while it's empty.
+var DriverNames = []string{}
+
 // PackageManager is the configuration for the package manager
 type PackageManager struct {
-	// Operations contains the commands for the package manager
+	// Driver is the built-in package manager to use ("apt", "dnf", "apk", "pacman", "pip"..., see DriverNames).
+	// When empty or "custom", the commands on Operations are used.
+	Driver string `default:"" json:"driver,omitempty"`
+
+	// Operations contains the commands for the package manager (only used by the "custom" driver)
 	Operations Operations `json:"operations"`
 
 	// Modifiers enable prefixs or suffixs on packages names with a string (see configs/nix/unstable.json)
+	//
+	// The "pin" modifier is used to install the versions on the lock file (for example: "{package}={version}")
 	Modifiers map[string]string `default:"{}" json:"modifiers"`
+
+	// Packages and DevPackages are the packages of the package managers on Image.PkgManagers,
+	// the system package manager uses the lists at the root of the config.
+	Packages    []string `json:"packages,omitempty"`
+	DevPackages []string `json:"devpackages,omitempty"`
+
+	// User runs the operations as the user instead of root (for example, for "pip install --user")
+	User bool `json:"user,omitempty"`
 }
 
+// SystemPkgManager is the name of the package manager on Image.PkgManager
+const SystemPkgManager = "system"
+
 // Image contains the information for the image
 type Image struct {
 	// URI is the location of the image
@@ -64,9 +95,12 @@ type Image struct {
 	// OnFinish is a list of commands to run on finish
 	OnFinish []string `default:"[]" json:"on_finish"`
 
-	// PkgManager contains the configuration for the package manager
+	// PkgManager contains the configuration for the package manager of the system
 	PkgManager PackageManager `json:"pkgmanager"`
 
+	// PkgManagers are more package managers (like pip or npm), each with its own packages
+	PkgManagers map[string]PackageManager `json:"pkgmanagers,omitempty"`
+
 	// Variables is a list of environment variables to set
 	Variables map[string]string `default:"{}" json:"variables"`
 }
@@ -115,6 +149,15 @@ type Podman struct {
 	// Path is the path to the podman executable
 	Path string `default:"podman" json:"path"`
 
+	// Engine is the kind of container engine to use ("podman", "docker" or "nerdctl"), detected from Path if empty
+	Engine string `default:"" json:"engine"`
+
+	// API tells develbox to talk to the engine's REST API (using the CLI as a fallback)
+	API bool `default:"false" json:"api"`
+
+	// Socket is the path to the API socket, the engine's default location is used if empty
+	Socket string `default:"" json:"socket"`
+
 	// Args is a list of arguments to pass to the podman executable
 	Args []string `default:"[]" json:"args"`
 
@@ -133,6 +176,10 @@ type Podman struct {
 
 // Structure is the main configuration struct
 type Structure struct {
+	// Extends are the configs this one is merged over: files, presets (like "debian/bullseye") or URLs.
+	// Only set on the files, Read returns the merged config.
+	Extends interface{} `json:"extends,omitempty"`
+
 	// Image contains the information for the image
 	Image Image `json:"image"`
 
@@ -156,15 +203,56 @@ type Structure struct {
 
 	// Experiments is a list of experimental features to enable
 	Experiments v1config.Experiments `json:"experiments"`
+
+	// Profiles are variants of the config (like "ci" or "gui"), selected with --profile or DEVELBOX_PROFILE
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+}
+
+// Profile is a partial config merged over the rest of the config when it's selected.
+// Objects are merged, the rest of the values (lists too) are replaced.
+type Profile map[string]interface{}
+
+// GetPkgManager returns the package manager with that name, the system one is returned for "" and "system"
+func (cfg *Structure) GetPkgManager(name string) (PackageManager, error) {
+	if name == "" || name == SystemPkgManager {
+		return cfg.Image.PkgManager, nil
+	}
+	pkgManager, ok := cfg.Image.PkgManagers[name]
+	if !ok {
+		return PackageManager{}, fmt.Errorf("there's no package manager named '%s' on image.pkgmanagers", name)
+	}
+	return pkgManager, nil
+}
+
+// PkgManagerNames returns the names of the package managers on Image.PkgManagers, sorted
+func (cfg *Structure) PkgManagerNames() []string {
+	names := []string{}
+	for name := range cfg.Image.PkgManagers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 // SetName sets the name of the container
 func SetName(cfg *Structure) {
 	if cfg.Container.Name == "" {
-		cfg.Container.Name = fmt.Sprintf("develbox-%s", v1config.GetDirNmHash()[:32])
+		cfg.Container.Name = DefaultName()
 	}
 }
 
+// DefaultName returns the container name used when the config doesn't set one. It's derived from the absolute path of the project.
+func DefaultName() string {
+	return fmt.Sprintf("develbox-%s", GetPathHash(GetCurrentDirectory())[:32])
+}
+
+// LegacyName returns the default container name used by older versions, derived only from the project folder's name.
+//
+// Two projects with the same folder name got the same container, see container.MigrateName.
+func LegacyName() string {
+	return fmt.Sprintf("develbox-%s", GetDirNmHash()[:32])
+}
+
 // SetDefaults sets the default values for the configuration
 func SetDefaults(cfg *Structure) {
 	defaults.Set(cfg)
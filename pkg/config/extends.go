@@ -0,0 +1,347 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/kpango/glg"
+)
+
+var (
+	// PresetSource is where the presets used by "extends" (like "debian/bullseye") are downloaded from, <PresetSource>/<preset>.json.
+	// It can also be a folder.
+	PresetSource = "https://raw.githubusercontent.com/kadmuffin/develbox/main/configs"
+
+	// FetchTimeout is how long a download of a config on "extends" can take
+	FetchTimeout = 10 * time.Second
+
+	// ExtendsCacheTTL is how long a downloaded config is used before it's downloaded again
+	ExtendsCacheTTL = 24 * time.Hour
+)
+
+// layer is a config file, with its own values and the merged values of the configs it extends
+type layer struct {
+	path   string
+	format string
+	data   []byte
+
+	// own are the values on the file, without "extends"
+	own map[string]interface{}
+
+	// base are the values of the configs it extends, merged in order (nil if it doesn't extend any)
+	base map[string]interface{}
+}
+
+// loadLayer reads the config file and the configs it extends
+func loadLayer(path string, data []byte) (*layer, error) {
+	l := &layer{path: path, format: FormatOf(path), data: data}
+
+	own, err := decodeDocument(data, l.format)
+	if err != nil {
+		return nil, err
+	}
+
+	absolute, _ := filepath.Abs(path)
+	l.own, l.base, err = resolveExtends(own, filepath.Dir(absolute), map[string]bool{absolute: true})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return l, nil
+}
+
+// merged returns the values of the layer over the ones it extends
+func (l *layer) merged() map[string]interface{} {
+	if l.base == nil {
+		return l.own
+	}
+	return mergeValues(l.base, l.own).(map[string]interface{})
+}
+
+// isV1 returns true if the file is a v1 config (they can't extend other configs)
+func (l *layer) isV1() bool {
+	podman, ok := l.own["podman"].(map[string]interface{})
+	return ok && l.own["container"] == nil && podman["container"] != nil
+}
+
+// resolveExtends reads the configs on the "extends" key of the document and merges them.
+// location is the folder (or URL) that relative files are read from, seen detects cycles.
+func resolveExtends(document map[string]interface{}, location string, seen map[string]bool) (own, base map[string]interface{}, err error) {
+	own = map[string]interface{}{}
+	for key, value := range document {
+		if key != "extends" {
+			own[key] = value
+		}
+	}
+
+	refs, err := extendsList(document["extends"])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, ref := range refs {
+		source := extendsSource(ref, location)
+		if seen[source] {
+			return nil, nil, fmt.Errorf("'%s' is extended in a loop", ref)
+		}
+
+		data, err := readSource(source)
+		if err != nil {
+			return nil, nil, fmt.Errorf("can't read '%s': %s", ref, err)
+		}
+		extended, err := decodeDocument(data, FormatOf(source))
+		if err != nil {
+			return nil, nil, fmt.Errorf("can't read '%s': %s", ref, err)
+		}
+
+		seen[source] = true
+		extendedOwn, extendedBase, err := resolveExtends(extended, sourceLocation(source), seen)
+		delete(seen, source)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", ref, err)
+		}
+
+		if extendedBase != nil {
+			extendedOwn = mergeValues(extendedBase, extendedOwn).(map[string]interface{})
+		}
+		if base == nil {
+			base = extendedOwn
+		} else {
+			base = mergeValues(base, extendedOwn).(map[string]interface{})
+		}
+	}
+	return own, base, nil
+}
+
+// extendsList reads the "extends" key, a string or a list of strings
+func extendsList(value interface{}) ([]string, error) {
+	switch value := value.(type) {
+	case nil:
+		return []string{}, nil
+	case string:
+		return []string{value}, nil
+	case []interface{}:
+		refs := []string{}
+		for _, item := range value {
+			ref, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("extends has to be a string or a list of strings")
+			}
+			refs = append(refs, ref)
+		}
+		return refs, nil
+	}
+	return nil, fmt.Errorf("extends has to be a string or a list of strings")
+}
+
+// extendsSource returns the file or URL of a reference: a URL, a file (relative to location) or a preset name
+func extendsSource(ref, location string) string {
+	if isURL(ref) {
+		return ref
+	}
+
+	isFile := strings.HasPrefix(ref, ".") || strings.HasPrefix(ref, "/") || strings.HasPrefix(ref, "~") || FormatOf(ref) != "json" || filepath.Ext(ref) == ".json"
+	if !isFile {
+		return fmt.Sprintf("%s/%s.json", strings.TrimSuffix(PresetSource, "/"), ref)
+	}
+
+	if strings.HasPrefix(ref, "~/") {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, ref[2:])
+	}
+	if isURL(location) {
+		base, err := url.Parse(location + "/")
+		if err == nil {
+			if resolved, err := base.Parse(ref); err == nil {
+				return resolved.String()
+			}
+		}
+	}
+	if filepath.IsAbs(ref) {
+		return ref
+	}
+	return filepath.Join(location, ref)
+}
+
+// sourceLocation returns where the relative references of a source are read from
+func sourceLocation(source string) string {
+	if isURL(source) {
+		return source[:strings.LastIndex(source, "/")]
+	}
+	return filepath.Dir(source)
+}
+
+// readSource reads a file or downloads a URL (see fetchSource)
+func readSource(source string) ([]byte, error) {
+	if !isURL(source) {
+		return os.ReadFile(source)
+	}
+	return fetchSource(source)
+}
+
+// fetchSource downloads the URL. The copy on the cache (see CachePath) is used while it's newer than ExtendsCacheTTL,
+// or when the download fails, so the commands work offline.
+func fetchSource(source string) ([]byte, error) {
+	cached := CachePath(source)
+	if info, err := os.Stat(cached); err == nil && time.Since(info.ModTime()) < ExtendsCacheTTL {
+		if data, err := os.ReadFile(cached); err == nil {
+			return data, nil
+		}
+	}
+
+	data, err := download(source)
+	if err != nil {
+		if data, cacheErr := os.ReadFile(cached); cacheErr == nil {
+			glg.Warnf("Can't download %s, using the cached copy: %s", source, err)
+			return data, nil
+		}
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cached), 0755); err == nil {
+		err = WriteFileAtomic(cached, data, 0644)
+	}
+	if err != nil {
+		glg.Debugf("Can't cache %s: %s", source, err)
+	}
+	return data, nil
+}
+
+// download gets the URL, giving up after FetchTimeout
+func download(source string) ([]byte, error) {
+	client := &http.Client{Timeout: FetchTimeout}
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the server returned %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// CachePath returns where the downloaded config is cached, $XDG_CACHE_HOME/develbox/extends/<hash of the URL>
+func CachePath(source string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "develbox", "extends", GetPathHash(source)+filepath.Ext(source))
+}
+
+// isURL returns true for http and https URLs
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// decodeDocument decodes a config as generic values (numbers are kept as json.Number)
+func decodeDocument(data []byte, format string) (map[string]interface{}, error) {
+	jsonData, err := toJSON(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	document := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	if document == nil {
+		document = map[string]interface{}{}
+	}
+	return document, nil
+}
+
+// mergeValues merges a value over the one it extends: objects are merged, lists are appended (items that are
+// already on the list aren't repeated) and the rest is replaced. null doesn't replace anything.
+func mergeValues(base, over interface{}) interface{} {
+	switch over := over.(type) {
+	case nil:
+		return base
+	case map[string]interface{}:
+		baseObject, ok := base.(map[string]interface{})
+		if !ok {
+			return over
+		}
+		merged := map[string]interface{}{}
+		for key, value := range baseObject {
+			merged[key] = value
+		}
+		for key, value := range over {
+			merged[key] = mergeValues(baseObject[key], value)
+		}
+		return merged
+	case []interface{}:
+		baseList, ok := base.([]interface{})
+		if !ok {
+			return over
+		}
+		merged := append([]interface{}{}, baseList...)
+		for _, item := range over {
+			if indexOfValue(merged, item) < 0 {
+				merged = append(merged, item)
+			}
+		}
+		return merged
+	}
+	return over
+}
+
+// indexOfValue returns the position of the value on the list, or -1
+func indexOfValue(list []interface{}, value interface{}) int {
+	for i, item := range list {
+		if reflect.DeepEqual(item, value) {
+			return i
+		}
+	}
+	return -1
+}
+
+// MarshalJSON encodes the object keeping the order of the keys
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, member := range o {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		key, err := json.Marshal(member.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(member.Value)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,272 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// Formats are the extensions of the config files, .develbox/config.<extension> is looked up in this order
+	Formats = []string{"json", "yaml", "yml", "toml"}
+
+	// NewFileFormat is the extension used when the project doesn't have a config file yet
+	NewFileFormat = "json"
+)
+
+// Path returns the config file of the project (.develbox/config.<NewFileFormat> if there isn't one)
+func Path() string {
+	for _, extension := range Formats {
+		path := ".develbox/config." + extension
+		if FileExists(path) {
+			return path
+		}
+	}
+	return ".develbox/config." + NewFileFormat
+}
+
+// FormatOf returns the format of a config file from its extension (json, yaml or toml)
+func FormatOf(path string) string {
+	switch strings.TrimPrefix(filepath.Ext(path), ".") {
+	case "yaml", "yml":
+		return "yaml"
+	case "toml":
+		return "toml"
+	}
+	return "json"
+}
+
+// toJSON converts a document to JSON, so every format is decoded by the same code (and the interface{} fields get the same values)
+func toJSON(data []byte, format string) ([]byte, error) {
+	var document interface{}
+	switch format {
+	case "yaml":
+		if err := yaml.Unmarshal(data, &document); err != nil {
+			return nil, err
+		}
+	case "toml":
+		table := map[string]interface{}{}
+		if err := toml.Unmarshal(data, &table); err != nil {
+			return nil, err
+		}
+		document = table
+	default:
+		return data, nil
+	}
+	return json.Marshal(document)
+}
+
+// Marshal encodes the whole config (or any value) in the format
+func Marshal(configs interface{}, format string) ([]byte, error) {
+	var buffer bytes.Buffer
+	switch format {
+	case "yaml":
+		value, err := orderedValue(configs)
+		if err != nil {
+			return nil, err
+		}
+		encoder := yaml.NewEncoder(&buffer)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(yamlNode(value)); err != nil {
+			return nil, err
+		}
+	case "toml":
+		value, err := orderedValue(configs)
+		if err != nil {
+			return nil, err
+		}
+		if err := toml.NewEncoder(&buffer).Encode(tomlValue(value)); err != nil {
+			return nil, err
+		}
+	default:
+		encoder := json.NewEncoder(&buffer)
+		encoder.SetIndent("", "\t")
+		if err := encoder.Encode(configs); err != nil {
+			return nil, err
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+// editDocument changes only the values that are different between from and to.
+// TOML files don't keep their format, they are encoded again.
+func editDocument(document []byte, format string, from, to interface{}) ([]byte, error) {
+	switch format {
+	case "yaml":
+		return EditYAML(document, from, to)
+	case "toml":
+		return Marshal(to, format)
+	}
+	return EditJSON(document, from, to)
+}
+
+// EditYAML is EditJSON for YAML documents, the comments of the document are kept
+func EditYAML(document []byte, from, to interface{}) ([]byte, error) {
+	oldValue, err := orderedValue(from)
+	if err != nil {
+		return nil, err
+	}
+	newValue, err := orderedValue(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(document, &root); err != nil {
+		return nil, err
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("the document isn't a mapping")
+	}
+
+	for _, edit := range diffJSON([]string{}, oldValue, newValue) {
+		if err := applyYAMLEdit(root.Content[0], edit); err != nil {
+			return nil, fmt.Errorf("can't edit '%s': %s", strings.Join(edit.path, "."), err)
+		}
+	}
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(detectYAMLIndent(document))
+	if err := encoder.Encode(&root); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// applyYAMLEdit changes a single value of the mapping
+func applyYAMLEdit(mapping *yaml.Node, edit jsonEdit) error {
+	if len(edit.path) == 0 {
+		return fmt.Errorf("the document isn't a mapping")
+	}
+	for _, key := range edit.path[:len(edit.path)-1] {
+		index := yamlKeyIndex(mapping, key)
+		if index < 0 {
+			return fmt.Errorf("'%s' doesn't exist", key)
+		}
+		mapping = mapping.Content[index+1]
+		if mapping.Kind != yaml.MappingNode {
+			return fmt.Errorf("'%s' isn't a mapping", key)
+		}
+	}
+
+	key := edit.path[len(edit.path)-1]
+	index := yamlKeyIndex(mapping, key)
+	switch {
+	case edit.remove:
+		if index >= 0 {
+			mapping.Content = append(mapping.Content[:index], mapping.Content[index+2:]...)
+		}
+	case index >= 0:
+		old := mapping.Content[index+1]
+		node := yamlNode(edit.value)
+		node.LineComment, node.FootComment = old.LineComment, old.FootComment
+		if node.Kind == old.Kind {
+			node.Style = old.Style
+		}
+		mapping.Content[index+1] = node
+	case edit.value != nil:
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, yamlNode(edit.value))
+	}
+	return nil
+}
+
+// yamlKeyIndex returns the position of the key on the mapping, or -1
+func yamlKeyIndex(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// yamlNode converts a value from orderedValue to a YAML node, null members are left out
+func yamlNode(value interface{}) *yaml.Node {
+	switch value := value.(type) {
+	case jsonObject:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, member := range value {
+			if member.Value == nil {
+				continue
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: member.Key}, yamlNode(member.Value))
+		}
+		return node
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range value {
+			node.Content = append(node.Content, yamlNode(item))
+		}
+		return node
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: value.String()}
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: value.String()}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(value)}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+}
+
+// tomlValue converts a value from orderedValue to values go-toml can encode (TOML doesn't have null)
+func tomlValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case jsonObject:
+		table := map[string]interface{}{}
+		for _, member := range value {
+			if member.Value != nil {
+				table[member.Key] = tomlValue(member.Value)
+			}
+		}
+		return table
+	case []interface{}:
+		list := []interface{}{}
+		for _, item := range value {
+			if item != nil {
+				list = append(list, tomlValue(item))
+			}
+		}
+		return list
+	case json.Number:
+		if number, err := value.Int64(); err == nil {
+			return number
+		}
+		number, _ := value.Float64()
+		return number
+	}
+	return value
+}
+
+// detectYAMLIndent returns the spaces of the first indented line (2 if there isn't one)
+func detectYAMLIndent(data []byte) int {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") && len(trimmed) < len(line) {
+			return len(line) - len(trimmed)
+		}
+	}
+	return 2
+}
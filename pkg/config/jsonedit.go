@@ -0,0 +1,401 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// jsonObject is a JSON object that keeps the order of its keys
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	Key   string
+	Value interface{}
+}
+
+// jsonEdit sets (or removes) the value on path
+type jsonEdit struct {
+	path   []string
+	value  interface{}
+	remove bool
+}
+
+// memberSpan is where a member of an object is on the document
+type memberSpan struct {
+	key                                    string
+	keyStart, keyEnd, valueStart, valueEnd int
+}
+
+// EditJSON changes the document so it goes from the values of "from" to the values of "to".
+//
+// Only the values that are different are rewritten, the rest of the document (formatting, key order and
+// keys that aren't on the structs) stays as it was.
+func EditJSON(document []byte, from, to interface{}) ([]byte, error) {
+	oldValue, err := orderedValue(from)
+	if err != nil {
+		return nil, err
+	}
+	newValue, err := orderedValue(to)
+	if err != nil {
+		return nil, err
+	}
+
+	indent := detectIndent(document)
+	for _, edit := range diffJSON([]string{}, oldValue, newValue) {
+		document, err = applyEdit(document, edit, indent)
+		if err != nil {
+			return nil, fmt.Errorf("can't edit '%s': %s", strings.Join(edit.path, "."), err)
+		}
+	}
+	return document, nil
+}
+
+// orderedValue encodes v as JSON and decodes it again keeping the order of the keys
+func orderedValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decodeOrdered(decoder)
+}
+
+// decodeOrdered decodes the next value, objects are returned as jsonObject
+func decodeOrdered(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := jsonObject{}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			object = append(object, jsonMember{Key: key.(string), Value: value})
+		}
+		_, err = decoder.Token()
+		return object, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for decoder.More() {
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err = decoder.Token()
+		return list, err
+	}
+	return token, nil
+}
+
+// get returns the value of the key
+func (o jsonObject) get(key string) (interface{}, bool) {
+	for _, member := range o {
+		if member.Key == key {
+			return member.Value, true
+		}
+	}
+	return nil, false
+}
+
+// diffJSON returns the edits needed to go from the old to the new value. Objects are compared key by key,
+// everything else (including lists) is replaced as a whole.
+func diffJSON(path []string, oldValue, newValue interface{}) []jsonEdit {
+	oldObject, oldIsObject := oldValue.(jsonObject)
+	newObject, newIsObject := newValue.(jsonObject)
+	if !oldIsObject || !newIsObject {
+		if reflect.DeepEqual(oldValue, newValue) {
+			return nil
+		}
+		return []jsonEdit{{path: path, value: newValue}}
+	}
+
+	edits := []jsonEdit{}
+	for _, member := range newObject {
+		subPath := append(append([]string{}, path...), member.Key)
+		if value, ok := oldObject.get(member.Key); ok {
+			edits = append(edits, diffJSON(subPath, value, member.Value)...)
+		} else {
+			edits = append(edits, jsonEdit{path: subPath, value: member.Value})
+		}
+	}
+	for _, member := range oldObject {
+		if _, ok := newObject.get(member.Key); !ok {
+			edits = append(edits, jsonEdit{path: append(append([]string{}, path...), member.Key), remove: true})
+		}
+	}
+	return edits
+}
+
+// applyEdit changes a single value of the document
+func applyEdit(data []byte, edit jsonEdit, indent string) ([]byte, error) {
+	if len(edit.path) == 0 {
+		return nil, fmt.Errorf("the document isn't an object")
+	}
+	parent, err := locate(data, edit.path[:len(edit.path)-1])
+	if err != nil {
+		return nil, err
+	}
+	members, end, err := objectMembers(data, parent)
+	if err != nil {
+		return nil, err
+	}
+
+	key := edit.path[len(edit.path)-1]
+	index := -1
+	for i, member := range members {
+		if member.key == key {
+			index = i
+		}
+	}
+
+	if edit.remove {
+		if index < 0 {
+			return data, nil
+		}
+		member := members[index]
+		switch {
+		case index+1 < len(members):
+			return splice(data, member.keyStart, members[index+1].keyStart, ""), nil
+		case index > 0:
+			return splice(data, members[index-1].valueEnd, member.valueEnd, ""), nil
+		default:
+			return splice(data, parent+1, end, ""), nil
+		}
+	}
+
+	if index >= 0 {
+		member := members[index]
+		value := renderJSON(edit.value, lineIndent(data, member.keyStart), indent)
+		return splice(data, member.valueStart, member.valueEnd, value), nil
+	}
+
+	encodedKey, _ := json.Marshal(key)
+	if len(members) == 0 {
+		prefix := lineIndent(data, parent)
+		if indent == "" {
+			return splice(data, parent+1, end, string(encodedKey)+": "+renderJSON(edit.value, "", "")), nil
+		}
+		value := renderJSON(edit.value, prefix+indent, indent)
+		return splice(data, parent+1, end, "\n"+prefix+indent+string(encodedKey)+": "+value+"\n"+prefix), nil
+	}
+
+	// New keys go after the last one, with the same spacing as the first one
+	first, last := members[0], members[len(members)-1]
+	separator := string(data[parent+1 : first.keyStart])
+	colon := string(data[first.keyEnd:first.valueStart])
+	value := renderJSON(edit.value, lineIndent(data, first.keyStart), indent)
+	return splice(data, last.valueEnd, last.valueEnd, ","+separator+string(encodedKey)+colon+value), nil
+}
+
+// locate returns where the object on path starts
+func locate(data []byte, path []string) (int, error) {
+	position := skipSpace(data, 0)
+	for _, key := range path {
+		members, _, err := objectMembers(data, position)
+		if err != nil {
+			return 0, err
+		}
+
+		found := false
+		for _, member := range members {
+			if member.key == key {
+				position, found = member.valueStart, true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("'%s' doesn't exist", key)
+		}
+	}
+
+	if position >= len(data) || data[position] != '{' {
+		return 0, fmt.Errorf("not an object")
+	}
+	return position, nil
+}
+
+// objectMembers returns the members of the object that starts at i and where it ends
+func objectMembers(data []byte, i int) ([]memberSpan, int, error) {
+	if i >= len(data) || data[i] != '{' {
+		return nil, 0, fmt.Errorf("not an object")
+	}
+
+	members := []memberSpan{}
+	j := skipSpace(data, i+1)
+	if j < len(data) && data[j] == '}' {
+		return members, j, nil
+	}
+
+	for j < len(data) {
+		if data[j] != '"' {
+			return nil, 0, fmt.Errorf("expected a key at %d", j)
+		}
+		member := memberSpan{keyStart: j}
+		end, err := valueEnd(data, j)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := json.Unmarshal(data[j:end], &member.key); err != nil {
+			return nil, 0, err
+		}
+		member.keyEnd = end
+
+		j = skipSpace(data, end)
+		if j >= len(data) || data[j] != ':' {
+			return nil, 0, fmt.Errorf("expected ':' at %d", j)
+		}
+		member.valueStart = skipSpace(data, j+1)
+		member.valueEnd, err = valueEnd(data, member.valueStart)
+		if err != nil {
+			return nil, 0, err
+		}
+		members = append(members, member)
+
+		j = skipSpace(data, member.valueEnd)
+		if j < len(data) && data[j] == '}' {
+			return members, j, nil
+		}
+		if j >= len(data) || data[j] != ',' {
+			return nil, 0, fmt.Errorf("expected ',' at %d", j)
+		}
+		j = skipSpace(data, j+1)
+	}
+	return nil, 0, fmt.Errorf("unexpected end of the document")
+}
+
+// valueEnd returns where the value that starts at i ends
+func valueEnd(data []byte, i int) (int, error) {
+	if i >= len(data) {
+		return 0, fmt.Errorf("unexpected end of the document")
+	}
+
+	depth := 0
+	for j := i; j < len(data); j++ {
+		switch data[j] {
+		case '"':
+			j++
+			for j < len(data) && data[j] != '"' {
+				if data[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if depth == 0 {
+				return j + 1, nil
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return j + 1, nil
+			}
+		case ',', ' ', '\t', '\r', '\n':
+			if depth == 0 {
+				return j, nil
+			}
+		}
+	}
+	if depth != 0 {
+		return 0, fmt.Errorf("unexpected end of the document")
+	}
+	return len(data), nil
+}
+
+// skipSpace returns the position of the next character that isn't a space
+func skipSpace(data []byte, i int) int {
+	for i < len(data) && strings.ContainsRune(" \t\r\n", rune(data[i])) {
+		i++
+	}
+	return i
+}
+
+// lineIndent returns the spaces at the start of the line that has the position
+func lineIndent(data []byte, position int) string {
+	start := bytes.LastIndexByte(data[:position], '\n') + 1
+	end := start
+	for end < position && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+	return string(data[start:end])
+}
+
+// detectIndent returns the indentation of the first indented line, or "" if the document is on a single line
+func detectIndent(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n")[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && len(trimmed) < len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return ""
+}
+
+// splice replaces data[from:to] with text
+func splice(data []byte, from, to int, text string) []byte {
+	edited := append([]byte{}, data[:from]...)
+	edited = append(edited, text...)
+	return append(edited, data[to:]...)
+}
+
+// renderJSON encodes the value, lines after the first start with prefix. An empty indent renders it on a single line.
+func renderJSON(value interface{}, prefix, indent string) string {
+	newline, closing := "\n"+prefix+indent, "\n"+prefix
+	if indent == "" {
+		newline, closing = "", ""
+	}
+
+	switch value := value.(type) {
+	case jsonObject:
+		if len(value) == 0 {
+			return "{}"
+		}
+		members := []string{}
+		for _, member := range value {
+			key, _ := json.Marshal(member.Key)
+			members = append(members, newline+string(key)+": "+renderJSON(member.Value, prefix+indent, indent))
+		}
+		return "{" + strings.Join(members, ",") + closing + "}"
+	case []interface{}:
+		if len(value) == 0 {
+			return "[]"
+		}
+		items := []string{}
+		for _, item := range value {
+			items = append(items, newline+renderJSON(item, prefix+indent, indent))
+		}
+		return "[" + strings.Join(items, ",") + closing + "]"
+	}
+
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
@@ -0,0 +1,318 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/kpango/glg"
+)
+
+// stack is the project config between the global config of the user (below) and the local one (above)
+type stack struct {
+	// layers are merged in order, the later ones win
+	layers  []*layer
+	project int
+}
+
+// LocalPath returns the config of the project that isn't committed, .develbox/config.local.<extension>
+func LocalPath() string {
+	return findConfig(".develbox/config.local")
+}
+
+// GlobalPath returns the defaults of the user, $XDG_CONFIG_HOME/develbox/config.<extension> (~/.config if it isn't set)
+func GlobalPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".config")
+	}
+	return findConfig(filepath.Join(dir, "develbox", "config"))
+}
+
+// findConfig returns the first file with one of the Formats, prefix.json if there isn't one
+func findConfig(prefix string) string {
+	for _, extension := range Formats {
+		if FileExists(prefix + "." + extension) {
+			return prefix + "." + extension
+		}
+	}
+	return prefix + ".json"
+}
+
+// loadStack reads the project config and the global and local configs (if they exist)
+func loadStack() (*stack, error) {
+	return loadPaths(GlobalPath(), Path(), LocalPath())
+}
+
+// loadPaths reads the layers of a stack, only the project config has to exist
+func loadPaths(global, project, local string) (*stack, error) {
+	s := &stack{}
+	for _, path := range []string{global, project, local} {
+		isProject := path == project
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) && !isProject {
+				continue
+			}
+			return nil, err
+		}
+
+		l, err := loadLayer(path, data)
+		if err != nil {
+			return nil, err
+		}
+		if isProject {
+			s.project = len(s.layers)
+		}
+		s.layers = append(s.layers, l)
+	}
+	return s, nil
+}
+
+// ReadProject reads the config of the project on dir, merged like Read does when it runs from dir (v1 configs aren't converted).
+// The error is os.ErrNotExist if the project doesn't have a config.
+func ReadProject(dir string) (Structure, error) {
+	s, err := loadPaths(GlobalPath(), findConfig(filepath.Join(dir, ".develbox", "config")), findConfig(filepath.Join(dir, ".develbox", "config.local")))
+	if err != nil {
+		return Structure{}, err
+	}
+	cfg, _, err := s.read()
+	return cfg, err
+}
+
+// read parses the merged values of the layers, the selected profile and the overrides (see overrides).
+// v1 configs are read alone, they are converted first.
+func (s *stack) read() (Structure, bool, error) {
+	project := s.layers[s.project]
+	if project.isV1() {
+		return project.read()
+	}
+
+	merged := map[string]interface{}{}
+	for _, l := range s.layers {
+		merged = mergeValues(merged, l.merged()).(map[string]interface{})
+	}
+	if name := SelectedProfile(); name != "" {
+		var err error
+		if merged, err = applyProfile(merged, name); err != nil {
+			return Structure{}, false, err
+		}
+	}
+	over, err := overrides()
+	if err != nil {
+		return Structure{}, false, err
+	}
+	result := replaceValues(merged, over)
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return Structure{}, false, err
+	}
+	return ReadBytes(data)
+}
+
+// edit returns the documents of the layers that have to change so the merged config becomes configs.
+//
+// Values are changed on the last layer that sets them (the project if none does). Items removed from a list
+// are removed from the layer they are on, new items are added to the project. The overrides aren't written,
+// they are on both sides of the diff.
+func (s *stack) edit(configs *Structure) (map[string][]byte, error) {
+	current, _, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	oldValue, err := orderedValue(&current)
+	if err != nil {
+		return nil, err
+	}
+	newValue, err := orderedValue(configs)
+	if err != nil {
+		return nil, err
+	}
+
+	owns := []map[string]interface{}{}
+	for _, l := range s.layers {
+		owns = append(owns, cloneValue(l.own).(map[string]interface{}))
+	}
+
+	for _, edit := range diffJSON([]string{}, oldValue, newValue) {
+		s.route(owns, edit, orderedLookup(oldValue, edit.path))
+	}
+
+	documents := map[string][]byte{}
+	for i, l := range s.layers {
+		if reflect.DeepEqual(l.own, owns[i]) {
+			continue
+		}
+		data, err := editDocument(l.data, l.format, l.own, owns[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", l.path, err)
+		}
+		documents[l.path] = data
+	}
+	return documents, nil
+}
+
+// route applies the edit to the own values of the layers
+func (s *stack) route(owns []map[string]interface{}, edit jsonEdit, oldValue interface{}) {
+	if edit.remove {
+		for _, own := range owns {
+			deletePath(own, edit.path)
+		}
+		return
+	}
+
+	oldList, oldIsList := oldValue.([]interface{})
+	newList, newIsList := edit.value.([]interface{})
+	if !oldIsList || !newIsList {
+		target := s.project
+		for i := range owns {
+			if _, ok := lookupPath(owns[i], edit.path); ok {
+				target = i
+			}
+		}
+		setPath(owns[target], edit.path, plainValue(edit.value))
+		return
+	}
+
+	for _, item := range subtractValues(oldList, newList) {
+		removed := false
+		for i := len(owns) - 1; i >= 0 && !removed; i-- {
+			list, _ := lookupPath(owns[i], edit.path)
+			if items, ok := list.([]interface{}); ok {
+				if index := indexOfValue(items, item); index >= 0 {
+					setPath(owns[i], edit.path, append(append([]interface{}{}, items[:index]...), items[index+1:]...))
+					removed = true
+				}
+			}
+		}
+		if !removed {
+			glg.Warnf("%s: %v comes from a config on extends, it can't be removed", strings.Join(edit.path, "."), item)
+		}
+	}
+
+	for _, item := range subtractValues(newList, oldList) {
+		list, _ := lookupPath(owns[s.project], edit.path)
+		items, _ := list.([]interface{})
+		setPath(owns[s.project], edit.path, append(append([]interface{}{}, items...), plainValue(item)))
+	}
+}
+
+// subtractValues returns the items of list that aren't on other (repeated items are counted)
+func subtractValues(list, other []interface{}) []interface{} {
+	remaining := append([]interface{}{}, other...)
+	result := []interface{}{}
+	for _, item := range list {
+		if i := indexOfValue(remaining, item); i >= 0 {
+			remaining = append(remaining[:i], remaining[i+1:]...)
+			continue
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// orderedLookup returns the value on path of a value from orderedValue
+func orderedLookup(value interface{}, path []string) interface{} {
+	for _, key := range path {
+		object, ok := value.(jsonObject)
+		if !ok {
+			return nil
+		}
+		value, _ = object.get(key)
+	}
+	return value
+}
+
+// lookupPath returns the value on path of a decoded document
+func lookupPath(document map[string]interface{}, path []string) (interface{}, bool) {
+	var value interface{} = document
+	for _, key := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// setPath sets the value on path, the objects in between are created
+func setPath(document map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		child, ok := document[key].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			document[key] = child
+		}
+		document = child
+	}
+	document[path[len(path)-1]] = value
+}
+
+// deletePath removes the value on path
+func deletePath(document map[string]interface{}, path []string) {
+	parent, ok := lookupPath(document, path[:len(path)-1])
+	if object, isObject := parent.(map[string]interface{}); ok && isObject {
+		delete(object, path[len(path)-1])
+	}
+}
+
+// plainValue converts the objects of a value from orderedValue to maps
+func plainValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case jsonObject:
+		object := map[string]interface{}{}
+		for _, member := range value {
+			object[member.Key] = plainValue(member.Value)
+		}
+		return object
+	case []interface{}:
+		list := []interface{}{}
+		for _, item := range value {
+			list = append(list, plainValue(item))
+		}
+		return list
+	}
+	return value
+}
+
+// cloneValue copies the objects and lists of a decoded document
+func cloneValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		object := map[string]interface{}{}
+		for key, item := range value {
+			object[key] = cloneValue(item)
+		}
+		return object
+	case []interface{}:
+		list := []interface{}{}
+		for _, item := range value {
+			list = append(list, cloneValue(item))
+		}
+		return list
+	}
+	return value
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// ProjectLock is the file locked while a process changes the config or the packages of the project.
+// It's on .develbox/ so the CLI inside the container (on /code) and the socket on the host share it.
+const ProjectLock = ".develbox/.lock"
+
+// ConfigLock is the file locked while the config file is read and written
+const ConfigLock = ".develbox/.config.lock"
+
+// FileLock is an advisory lock (flock) on a file, other processes wait until it's unlocked
+type FileLock struct {
+	file *os.File
+}
+
+// Lock waits until it can lock the file on path (it's created if it doesn't exist)
+func Lock(path string) (*FileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &FileLock{file: file}, nil
+}
+
+// LockProject locks .develbox/, so the CLI and the socket don't change the config at the same time
+func LockProject() (*FileLock, error) {
+	return Lock(ProjectLock)
+}
+
+// Unlock releases the lock
+func (l *FileLock) Unlock() error {
+	defer l.file.Close()
+	return syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+}
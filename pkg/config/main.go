@@ -22,6 +22,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -30,79 +31,201 @@ import (
 	"github.com/spf13/viper"
 )
 
-// Read reads the config file and returns the Struct
+// Read reads the config file of the project (see Path) and returns the Struct.
+// The global config of the user and the local config of the project are merged with it, see loadStack.
 func Read() (cfg Structure, err error) {
 	var v1Cfg bool
-	cfg, v1Cfg, err = ReadFile(".develbox/config.json")
+	cfg, v1Cfg, err = readStack()
 	if err == nil && v1Cfg {
 		err = WriteNewVersion(&cfg)
 	}
+	if err == nil && v1Cfg {
+		// The overrides (and the global and local configs) are merged once it's converted
+		cfg, _, err = readStack()
+	}
 
 	return cfg, err
 }
 
-// ReadFile reads the config file  from a path and returns the Struct
-//
-// It converts the v1 config file to the v2 config file if it detects a v1 config file
-func ReadFile(path string) (Structure, bool, error) {
-	viper.SetConfigFile(path)
-
-	err := viper.ReadInConfig()
+// readStack reads the project config merged with the global and local ones
+func readStack() (Structure, bool, error) {
+	s, err := loadStack()
 	if err != nil {
 		return Structure{}, false, err
 	}
+	return s.read()
+}
 
-	// Read the config file
+// ReadFile reads the config file from a path and returns the Struct, the format is taken from the extension (see FormatOf).
+// v1 config files are converted to v2 (the bool is true when it was one), and the configs on "extends" are merged first, see mergeValues.
+func ReadFile(path string) (Structure, bool, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return Structure{}, false, err
 	}
 
-	return parseWithViper(data)
+	l, err := loadLayer(path, data)
+	if err != nil {
+		return Structure{}, false, err
+	}
+	return l.read()
+}
+
+// read parses the merged values of the layer
+func (l *layer) read() (Structure, bool, error) {
+	if l.base == nil || l.isV1() {
+		return ReadBytesAs(l.data, l.format)
+	}
+
+	data, err := json.Marshal(l.merged())
+	if err != nil {
+		return Structure{}, false, err
+	}
+	return ReadBytes(data)
 }
 
-// ReadBytes parses bytes and returns the Struct
+// ReadBytes parses JSON bytes and returns the Struct
 //
 // It converts the v1 config file to the v2 config file if it detects a v1 config file
 func ReadBytes(data []byte) (parsed Structure, wasV1Conf bool, err error) {
-	viper.SetConfigType("json")
+	return ReadBytesAs(data, "json")
+}
+
+// ReadBytesAs parses bytes in the format (json, yaml or toml) and returns the Struct
+func ReadBytesAs(data []byte, format string) (parsed Structure, wasV1Conf bool, err error) {
+	viper.SetConfigType(format)
 	err = viper.ReadConfig(bytes.NewBuffer(data))
 	if err != nil {
 		return Structure{}, false, err
 	}
 
+	data, err = toJSON(data, format)
+	if err != nil {
+		return Structure{}, false, err
+	}
 	return parseWithViper(data)
 }
 
-// Write writes the config file
+// Write writes the config file. It's locked while it's written, see WriteFileAtomic.
 func Write(configs *Structure) error {
-	glg.Infof("Writing config file to %s", ".develbox/config.json")
+	lock, err := Lock(ConfigLock)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	return write(configs)
+}
+
+// Update reads the config file, applies the change and writes it back while the config is locked.
+// Use it instead of Read and Write when other processes (like the socket) could change the config in between.
+func Update(change func(cfg *Structure) error) (Structure, error) {
+	lock, err := Lock(ConfigLock)
+	if err != nil {
+		return Structure{}, err
+	}
+	defer lock.Unlock()
+
+	cfg, v1Cfg, err := readStack()
+	if err != nil {
+		return cfg, err
+	}
+	if v1Cfg {
+		if err := backupV1(); err != nil {
+			return cfg, err
+		}
+	}
 
-	err := os.MkdirAll(".develbox", 0755)
+	if err := change(&cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, write(&cfg)
+}
+
+// write writes the config files that changed, the caller has to hold the lock
+func write(configs *Structure) error {
+	documents, err := encode(configs)
 	if err != nil {
 		return err
 	}
 
-	f, err := os.Create(".develbox/config.json")
+	for path, data := range documents {
+		glg.Infof("Writing config file to %s", path)
+		if err := WriteFileAtomic(path, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encode edits the config files so only the values that changed are rewritten (see EditJSON), each value is
+// written to the file it comes from (see stack.edit). The values inherited from the configs on "extends" aren't written.
+// The whole config is encoded on the project file if it doesn't exist, is a v1 config or can't be edited.
+func encode(configs *Structure) (map[string][]byte, error) {
+	path := Path()
+	if s, err := loadStack(); err != nil {
+		if FileExists(path) {
+			glg.Warnf("Can't keep the format of the config file, rewriting it: %s", err)
+		}
+	} else if !s.layers[s.project].isV1() {
+		documents, err := s.edit(configs)
+		if err == nil {
+			return documents, nil
+		}
+		glg.Warnf("Can't keep the format of the config file, rewriting it: %s", err)
+	}
+
+	data, err := Marshal(configs, FormatOf(path))
 	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{path: data}, nil
+}
+
+// WriteFileAtomic writes the data to a temporary file on the same folder, syncs it and renames it to path.
+// The file on path is either the old or the new one, never a half written one.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
-	defer f.Close()
 
-	encoder := json.NewEncoder(f)
-	encoder.SetIndent("", "\t")
-	err = encoder.Encode(configs)
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".*")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(f.Name())
 
-	return nil
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+
+	// Makes sure the rename is saved too
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
 }
 
 // Exists checks if the config file exists
 func Exists() bool {
-	_, err := os.Stat(".develbox/config.json")
-	exists := err == nil
+	exists := FileExists(Path())
 	if exists {
 		glg.Info("Config file exists!")
 	} else {
@@ -126,7 +249,7 @@ func GetCurrentDirectory() string {
 	return dir
 }
 
-// GetDirNmHash returns a hash made using the current directory's name.
+// GetDirNmHash returns a hash made using the current directory's name. Only used for the legacy container names, see LegacyName.
 func GetDirNmHash() string {
 	currentDirName := filepath.Base(GetCurrentDirectory())
 	hasher := sha256.New()
@@ -147,14 +270,27 @@ func GetPathHash(path string) string {
 func WriteNewVersion(configs *Structure) error {
 	glg.Warn("Updating v1 config file to new format (v2)... (a backup of the old config file will be saved as config.json.bak)")
 
-	// Backup the old config file
-	err := os.Rename(".develbox/config.json", ".develbox/config.json.bak")
+	lock, err := Lock(ConfigLock)
 	if err != nil {
 		return err
 	}
+	defer lock.Unlock()
 
-	// Write the new config file
-	return Write(configs)
+	// The old config file stays until the new one replaces it
+	if err := backupV1(); err != nil {
+		return err
+	}
+	return write(configs)
+}
+
+// backupV1 copies the v1 config file to config.json.bak
+func backupV1() error {
+	path := Path()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path+".bak", data, 0644)
 }
 
 // parseWithViper assumes viper is already configured and returns the parsed config
@@ -173,13 +309,29 @@ func parseWithViper(data []byte) (Structure, bool, error) {
 		}
 
 		parsed := ConvertFromV1(&v1Struct)
+		if err := ValidateTemplates(&parsed.Image.PkgManager); err != nil {
+			return Structure{}, true, err
+		}
 		return parsed, true, nil
 	}
 
 	var parsed Structure
 	err := decoder.Decode(&parsed)
 	if err != nil {
-		return Structure{}, false, nil
+		return Structure{}, false, fmt.Errorf("%s (run 'develbox config validate' to find the problems)", err)
+	}
+
+	if err := ValidateTemplates(&parsed.Image.PkgManager); err != nil {
+		return Structure{}, false, err
+	}
+	for _, name := range parsed.PkgManagerNames() {
+		pkgManager := parsed.Image.PkgManagers[name]
+		if name == SystemPkgManager {
+			return Structure{}, false, fmt.Errorf("'%s' can't be used as the name of a package manager, it's the one on image.pkgmanager", name)
+		}
+		if err := ValidateTemplates(&pkgManager); err != nil {
+			return Structure{}, false, fmt.Errorf("%s: %s", name, err)
+		}
 	}
 
 	SetName(&parsed)
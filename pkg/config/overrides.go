@@ -0,0 +1,181 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	// EnvPrefix starts the environment variables that override the config, like DEVELBOX_CONTAINER_SHELL
+	EnvPrefix = "DEVELBOX"
+
+	// Sets are the overrides from the --set flag ("container.shell=/bin/bash"), they win over the environment variables
+	Sets = []string{}
+)
+
+// overrides returns the values of the environment variables and Sets as a partial config document
+func overrides() (map[string]interface{}, error) {
+	document := map[string]interface{}{}
+
+	env := viper.New()
+	env.SetEnvPrefix(EnvPrefix)
+	env.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	env.AutomaticEnv()
+	for _, path := range fieldPaths(reflect.TypeOf(Structure{}), []string{}) {
+		key := strings.Join(path, ".")
+		raw := env.GetString(key)
+		if raw == "" {
+			continue
+		}
+
+		value, err := overrideValue(path, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s_%s: %s", EnvPrefix, strings.ToUpper(strings.ReplaceAll(key, ".", "_")), err)
+		}
+		setPath(document, path, value)
+	}
+
+	for _, set := range Sets {
+		key, raw, ok := strings.Cut(set, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("--set %s: expected key=value", set)
+		}
+
+		path := strings.Split(key, ".")
+		value, err := overrideValue(path, raw)
+		if err != nil {
+			return nil, fmt.Errorf("--set %s: %s", set, err)
+		}
+		setPath(document, path, value)
+	}
+	return document, nil
+}
+
+// fieldPaths returns the keys of the fields that can be set by environment variables (the fields of the structs,
+// "extends" and "profiles" aren't ones)
+func fieldPaths(t reflect.Type, prefix []string) [][]string {
+	paths := [][]string{}
+	for _, field := range jsonFields(t) {
+		name := jsonName(field)
+		if len(prefix) == 0 && (name == "extends" || name == "profiles") {
+			continue
+		}
+
+		path := append(append([]string{}, prefix...), name)
+		if field.Type.Kind() == reflect.Struct {
+			paths = append(paths, fieldPaths(field.Type, path)...)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// overrideValue parses the value of the key on path. Lists are separated by commas, and objects (or lists) can be written in JSON.
+func overrideValue(path []string, raw string) (interface{}, error) {
+	t, err := pathType(reflect.TypeOf(Structure{}), path)
+	if err != nil {
+		return nil, err
+	}
+
+	isJSON := strings.HasPrefix(raw, "{") || strings.HasPrefix(raw, "[")
+	switch t.Kind() {
+	case reflect.Bool:
+		return strconv.ParseBool(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.Atoi(raw)
+	case reflect.Slice:
+		if isJSON {
+			break
+		}
+		items := []interface{}{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items, nil
+	case reflect.Map, reflect.Struct:
+		if !isJSON {
+			return nil, fmt.Errorf("expected a JSON object")
+		}
+	case reflect.Interface:
+		if !isJSON {
+			return raw, nil
+		}
+	default:
+		return raw, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// pathType returns the type of the value on path, the keys of maps can be anything
+func pathType(t reflect.Type, path []string) (reflect.Type, error) {
+	for i, key := range path {
+		switch t.Kind() {
+		case reflect.Struct:
+			found := false
+			for _, field := range jsonFields(t) {
+				if jsonName(field) == key {
+					t, found = field.Type, true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("'%s' isn't a key of the config", strings.Join(path[:i+1], "."))
+			}
+		case reflect.Map:
+			t = t.Elem()
+		default:
+			return nil, fmt.Errorf("'%s' doesn't have keys", strings.Join(path[:i], "."))
+		}
+	}
+	return t, nil
+}
+
+// replaceValues merges objects like mergeValues, but the rest of the values (lists too) are replaced
+func replaceValues(base, over interface{}) interface{} {
+	switch over := over.(type) {
+	case nil:
+		return base
+	case map[string]interface{}:
+		baseObject, ok := base.(map[string]interface{})
+		if !ok {
+			return over
+		}
+		merged := map[string]interface{}{}
+		for key, value := range baseObject {
+			merged[key] = value
+		}
+		for key, value := range over {
+			merged[key] = replaceValues(baseObject[key], value)
+		}
+		return merged
+	}
+	return over
+}
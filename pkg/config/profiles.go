@@ -0,0 +1,53 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// ProfileName is the profile selected with --profile, DEVELBOX_PROFILE is used if it's empty
+var ProfileName = ""
+
+// SelectedProfile returns the name of the profile to use, "" if none is selected
+func SelectedProfile() string {
+	if ProfileName != "" {
+		return ProfileName
+	}
+	return os.Getenv(EnvPrefix + "_PROFILE")
+}
+
+// applyProfile merges the profile over the merged config (see replaceValues).
+// The container gets its own name (<name>-<profile>) unless the profile sets one, so the variants can coexist.
+func applyProfile(merged map[string]interface{}, name string) (map[string]interface{}, error) {
+	profiles, _ := merged["profiles"].(map[string]interface{})
+	profile, ok := profiles[name].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("there's no profile named '%s' on profiles", name)
+	}
+
+	if _, ok := lookupPath(profile, []string{"container", "name"}); !ok {
+		containerName, _ := lookupPath(merged, []string{"container", "name"})
+		base, _ := containerName.(string)
+		if base == "" {
+			base = DefaultName()
+		}
+		profile = replaceValues(profile, map[string]interface{}{
+			"container": map[string]interface{}{"name": fmt.Sprintf("%s-%s", base, name)},
+		}).(map[string]interface{})
+	}
+	return replaceValues(merged, profile).(map[string]interface{}), nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SchemaURL is where the schema of the config is published (configs/schema.json), editors use it when "$schema" points to it
+const SchemaURL = "https://raw.githubusercontent.com/kadmuffin/develbox/main/configs/schema.json"
+
+// extensionPrefix starts the keys that develbox ignores, so they can be used by other tools
+const extensionPrefix = "x-"
+
+// Schema returns the JSON Schema of the config file, generated from Structure
+func Schema() map[string]interface{} {
+	schema := typeSchema(reflect.TypeOf(Structure{}))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["$id"] = SchemaURL
+	schema["title"] = "develbox config"
+	schema["properties"].(map[string]interface{})["$schema"] = map[string]interface{}{"type": "string"}
+	return schema
+}
+
+// SchemaJSON returns the schema as it's published on configs/schema.json
+func SchemaJSON() ([]byte, error) {
+	data, err := json.MarshalIndent(Schema(), "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// typeSchema returns the schema of a Go type. Slices and maps can also be null, that's how empty ones are written.
+func typeSchema(t reflect.Type) map[string]interface{} {
+	if t == reflect.TypeOf(Profile{}) {
+		// Profiles have the same keys as the config
+		return map[string]interface{}{"$ref": "#"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		for _, field := range jsonFields(t) {
+			property := typeSchema(field.Type)
+			if value, ok := defaultValue(field); ok {
+				property["default"] = value
+			}
+			if t == reflect.TypeOf(PackageManager{}) && jsonName(field) == "driver" && len(DriverNames) > 0 {
+				// Empty is the same as "custom"
+				property["enum"] = append([]string{""}, DriverNames...)
+			}
+			properties[jsonName(field)] = property
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"patternProperties":    map[string]interface{}{"^" + extensionPrefix: map[string]interface{}{}},
+			"additionalProperties": false,
+		}
+	case reflect.Map:
+		return map[string]interface{}{"type": []string{"object", "null"}, "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Slice:
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": typeSchema(t.Elem())}
+	case reflect.Interface:
+		// The interface{} fields (commands and shared_folders) are a string or a list of strings
+		return map[string]interface{}{"oneOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		}}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	}
+	return map[string]interface{}{"type": "string"}
+}
+
+// jsonFields returns the fields of the struct that are on the config file
+func jsonFields(t reflect.Type) []reflect.StructField {
+	fields := []reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.IsExported() && jsonName(field) != "-" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// jsonName returns the key of the field on the config file
+func jsonName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// defaultValue returns the value of the "default" tag, when it's a string or a bool
+func defaultValue(field reflect.StructField) (interface{}, bool) {
+	value, ok := field.Tag.Lookup("default")
+	if !ok {
+		return nil, false
+	}
+
+	switch field.Type.Kind() {
+	case reflect.String:
+		return value, true
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		return parsed, err == nil
+	}
+	return nil, false
+}
@@ -0,0 +1,64 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kadmuffin/develbox/pkg/pkgm/template"
+)
+
+// ValidateTemplates parses the operations and modifiers of the package manager, so mistakes are found when the config is read
+func ValidateTemplates(cfg *PackageManager) error {
+	operations := cfg.Operations.Templates()
+	for _, name := range sortedKeys(operations) {
+		if err := template.Validate(operations[name], template.OperationSchema); err != nil {
+			return fmt.Errorf("pkgmanager.operations.%s: %s", name, err)
+		}
+	}
+
+	for _, name := range sortedKeys(cfg.Modifiers) {
+		if err := template.Validate(cfg.Modifiers[name], template.ModifierSchema); err != nil {
+			return fmt.Errorf("pkgmanager.modifiers.%s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// Templates returns the operations by their name on the config
+func (o Operations) Templates() map[string]string {
+	return map[string]string{
+		"add":       o.Add,
+		"del":       o.Del,
+		"update":    o.Upd,
+		"upgrade":   o.Upg,
+		"search":    o.Srch,
+		"clean":     o.Clean,
+		"query":     o.Query,
+		"installed": o.Installed,
+		"outdated":  o.Outdated,
+	}
+}
+
+// sortedKeys returns the keys of the map in order, so the errors are always the same
+func sortedKeys(values map[string]string) []string {
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,550 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kadmuffin/develbox/pkg/pkgm/template"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationError is a problem found on the config file
+type ValidationError struct {
+	// Path is where the problem is, like "container.ports[1]"
+	Path string `json:"path"`
+
+	// Line and Column start at 1, they are 0 when the position isn't known (for example, on TOML files)
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+
+	Message string `json:"message"`
+
+	keys []string
+}
+
+// packageOperations are the operations that get the packages, their templates have to use {packages} or {args}
+var packageOperations = []string{"add", "del", "search"}
+
+// yamlLineRegex finds the line on the errors of the YAML parser
+var yamlLineRegex = regexp.MustCompile(`line (\d+)`)
+
+// Error returns the problem as "line:column: path: message"
+func (e ValidationError) Error() string {
+	message := e.Message
+	if e.Path != "" {
+		message = e.Path + ": " + message
+	}
+
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, message)
+	case e.Line > 0:
+		return fmt.Sprintf("%d: %s", e.Line, message)
+	}
+	return message
+}
+
+// problem creates a ValidationError for the keys ("[1]" is the item of a list)
+func problem(keys []string, format string, args ...interface{}) ValidationError {
+	keys = append([]string{}, keys...)
+	return ValidationError{
+		Path:    strings.ReplaceAll(strings.Join(keys, "."), ".[", "["),
+		Message: fmt.Sprintf(format, args...),
+		keys:    keys,
+	}
+}
+
+// Validate checks the config file on path, see ValidateBytes
+func Validate(path string) ([]ValidationError, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ValidateBytes(data, FormatOf(path)), nil
+}
+
+// ValidateBytes checks a config in the format (json, yaml or toml) and returns every problem found: syntax errors, unknown keys,
+// values of the wrong type, ports and mounts that can't be parsed, and package manager templates that are broken or miss a placeholder.
+func ValidateBytes(data []byte, format string) []ValidationError {
+	if syntaxError := checkSyntax(data, format); syntaxError != nil {
+		return []ValidationError{*syntaxError}
+	}
+
+	jsonData, err := toJSON(data, format)
+	if err != nil {
+		return []ValidationError{{Message: err.Error()}}
+	}
+	var document interface{}
+	if err := json.Unmarshal(jsonData, &document); err != nil {
+		return []ValidationError{{Message: err.Error()}}
+	}
+
+	object, ok := document.(map[string]interface{})
+	if !ok {
+		return []ValidationError{{Message: "the config has to be an object"}}
+	}
+	if podman, ok := object["podman"].(map[string]interface{}); ok && object["container"] == nil && podman["container"] != nil {
+		return []ValidationError{{Message: "this is a v1 config, run any develbox command to convert it"}}
+	}
+
+	problems := checkValue([]string{}, object, reflect.TypeOf(Structure{}))
+
+	// Fields with the wrong type are left empty, the rest are still checked
+	var cfg Structure
+	json.Unmarshal(jsonData, &cfg)
+	problems = append(problems, checkStructure(&cfg)...)
+
+	position := positions(data, format)
+	for i := range problems {
+		problems[i].Line, problems[i].Column = position(problems[i].keys)
+	}
+	return problems
+}
+
+// checkSyntax returns the error of the parser, with its position
+func checkSyntax(data []byte, format string) *ValidationError {
+	switch format {
+	case "yaml":
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			syntaxError := &ValidationError{Message: err.Error()}
+			if match := yamlLineRegex.FindStringSubmatch(err.Error()); match != nil {
+				syntaxError.Line, _ = strconv.Atoi(match[1])
+			}
+			return syntaxError
+		}
+	case "toml":
+		var table map[string]interface{}
+		if err := toml.Unmarshal(data, &table); err != nil {
+			syntaxError := &ValidationError{Message: err.Error()}
+			var decodeError *toml.DecodeError
+			if errors.As(err, &decodeError) {
+				syntaxError.Line, syntaxError.Column = decodeError.Position()
+			}
+			return syntaxError
+		}
+	default:
+		var document interface{}
+		if err := json.Unmarshal(data, &document); err != nil {
+			syntaxError := &ValidationError{Message: err.Error()}
+			var jsonError *json.SyntaxError
+			if errors.As(err, &jsonError) {
+				syntaxError.Line, syntaxError.Column = offsetPosition(data, int(jsonError.Offset)-1)
+			}
+			return syntaxError
+		}
+	}
+	return nil
+}
+
+// checkValue compares the value with the Go type it's decoded to
+func checkValue(keys []string, value interface{}, t reflect.Type) []ValidationError {
+	if value == nil {
+		return nil
+	}
+
+	if t == reflect.TypeOf(Profile{}) {
+		return checkValue(keys, value, reflect.TypeOf(Structure{}))
+	}
+
+	problems := []ValidationError{}
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []ValidationError{problem(keys, "expected an object, got %s", kindOf(value))}
+		}
+
+		fields := map[string]reflect.Type{}
+		for _, field := range jsonFields(t) {
+			fields[jsonName(field)] = field.Type
+		}
+		for _, key := range objectKeys(object) {
+			fieldType, ok := fields[key]
+			switch {
+			case strings.HasPrefix(key, extensionPrefix) || (len(keys) == 0 && key == "$schema"):
+			case !ok:
+				problems = append(problems, problem(append(keys, key), "unknown key"))
+			default:
+				problems = append(problems, checkValue(append(keys, key), object[key], fieldType)...)
+			}
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []ValidationError{problem(keys, "expected an object, got %s", kindOf(value))}
+		}
+		for _, key := range objectKeys(object) {
+			problems = append(problems, checkValue(append(keys, key), object[key], t.Elem())...)
+		}
+	case reflect.Slice:
+		list, ok := value.([]interface{})
+		if !ok {
+			return []ValidationError{problem(keys, "expected a list, got %s", kindOf(value))}
+		}
+		for i, item := range list {
+			problems = append(problems, checkValue(append(keys, fmt.Sprintf("[%d]", i)), item, t.Elem())...)
+		}
+	case reflect.Interface:
+		if !isStringOrList(value) {
+			return []ValidationError{problem(keys, "expected a string or a list of strings, got %s", kindOf(value))}
+		}
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			return []ValidationError{problem(keys, "expected a boolean, got %s", kindOf(value))}
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if number, ok := value.(float64); !ok || number != float64(int64(number)) {
+			return []ValidationError{problem(keys, "expected an integer, got %s", kindOf(value))}
+		}
+	default:
+		if _, ok := value.(string); !ok {
+			return []ValidationError{problem(keys, "expected a string, got %s", kindOf(value))}
+		}
+	}
+	return problems
+}
+
+// checkStructure checks the values that have their own syntax
+func checkStructure(cfg *Structure) []ValidationError {
+	problems := []ValidationError{}
+	for i, port := range cfg.Container.Ports {
+		if err := checkPort(port); err != nil {
+			problems = append(problems, problem([]string{"container", "ports", fmt.Sprintf("[%d]", i)}, "invalid port '%s': %s", port, err))
+		}
+	}
+	for i, mount := range cfg.Container.Mounts {
+		if err := checkMount(mount); err != nil {
+			problems = append(problems, problem([]string{"container", "mounts", fmt.Sprintf("[%d]", i)}, "invalid mount '%s': %s", mount, err))
+		}
+	}
+
+	problems = append(problems, checkPkgManager([]string{"image", "pkgmanager"}, &cfg.Image.PkgManager)...)
+	for _, name := range cfg.PkgManagerNames() {
+		pkgManager := cfg.Image.PkgManagers[name]
+		problems = append(problems, checkPkgManager([]string{"image", "pkgmanagers", name}, &pkgManager)...)
+	}
+	return problems
+}
+
+// checkPkgManager checks the templates of the package manager and the placeholders they need
+func checkPkgManager(keys []string, pkgManager *PackageManager) []ValidationError {
+	problems := []ValidationError{}
+	custom := pkgManager.Driver == "" || pkgManager.Driver == "custom"
+	if !custom && len(DriverNames) > 0 && !containsString(DriverNames, pkgManager.Driver) {
+		driverKeys := append(append([]string{}, keys...), "driver")
+		problems = append(problems, problem(driverKeys, "unknown driver '%s', use one of %s", pkgManager.Driver, strings.Join(DriverNames, ", ")))
+	}
+
+	operations := pkgManager.Operations.Templates()
+	for _, name := range sortedKeys(operations) {
+		opKeys := append(append([]string{}, keys...), "operations", name)
+		tmpl, err := template.Parse(operations[name])
+		if err == nil {
+			err = template.Validate(operations[name], template.OperationSchema)
+		}
+		switch {
+		case err != nil:
+			problems = append(problems, problem(opKeys, "%s", err))
+		case custom && operations[name] != "" && containsString(packageOperations, name) && !tmpl.Uses("packages") && !tmpl.Uses("args"):
+			problems = append(problems, problem(opKeys, "the template doesn't use {packages} or {args}, the packages wouldn't be passed"))
+		}
+	}
+
+	for _, name := range sortedKeys(pkgManager.Modifiers) {
+		modKeys := append(append([]string{}, keys...), "modifiers", name)
+		tmpl, err := template.Parse(pkgManager.Modifiers[name])
+		if err == nil {
+			err = template.Validate(pkgManager.Modifiers[name], template.ModifierSchema)
+		}
+		if err != nil {
+			problems = append(problems, problem(modKeys, "%s", err))
+			continue
+		}
+
+		required := []string{"package"}
+		switch name {
+		case "pin":
+			required = append(required, "version")
+		case "repo":
+			required = append(required, "repo")
+		}
+		for _, variable := range required {
+			if !tmpl.Uses(variable) {
+				problems = append(problems, problem(modKeys, "the template doesn't use {%s}", variable))
+			}
+		}
+	}
+	return problems
+}
+
+// checkPort checks a port like podman reads it: "[ip:][host:]container[/protocol]", the ports can be ranges ("8080-8090")
+func checkPort(port string) error {
+	spec := port
+	if i := strings.LastIndex(spec, "/"); i >= 0 {
+		if protocol := spec[i+1:]; !containsString([]string{"tcp", "udp", "sctp"}, protocol) {
+			return fmt.Errorf("unknown protocol '%s' (use tcp, udp or sctp)", protocol)
+		}
+		spec = spec[:i]
+	}
+
+	ip := ""
+	if strings.HasPrefix(spec, "[") {
+		end := strings.Index(spec, "]:")
+		if end < 0 {
+			return fmt.Errorf("the IPv6 address isn't closed")
+		}
+		ip, spec = spec[1:end], spec[end+2:]
+	}
+
+	parts := strings.Split(spec, ":")
+	if len(parts) == 3 && ip == "" {
+		ip, parts = parts[0], parts[1:]
+	}
+	if len(parts) > 2 {
+		return fmt.Errorf("expected [ip:][host:]container")
+	}
+	if ip != "" && net.ParseIP(ip) == nil {
+		return fmt.Errorf("'%s' isn't an IP address", ip)
+	}
+
+	for i, ports := range parts {
+		// The host port can be empty after an IP ("127.0.0.1::80"), a random one is used
+		if ports == "" && i == 0 && len(parts) == 2 && ip != "" {
+			continue
+		}
+		if err := checkPortRange(ports); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkPortRange checks a port or a range of ports
+func checkPortRange(ports string) error {
+	bounds := strings.Split(ports, "-")
+	numbers := []int{}
+	for _, bound := range bounds {
+		number, err := strconv.Atoi(bound)
+		if err != nil || number < 1 || number > 65535 || len(bounds) > 2 {
+			return fmt.Errorf("'%s' isn't a port or a range of ports (like 8080 or 8080-8090)", ports)
+		}
+		numbers = append(numbers, number)
+	}
+	if len(numbers) == 2 && numbers[0] > numbers[1] {
+		return fmt.Errorf("the range '%s' ends before it starts", ports)
+	}
+	return nil
+}
+
+// checkMount checks a mount like the container package reads it: "host:container"
+func checkMount(mount string) error {
+	i := strings.LastIndex(mount, ":")
+	if i < 0 {
+		return fmt.Errorf("expected host:container")
+	}
+
+	host, target := mount[:i], mount[i+1:]
+	if host == "" {
+		return fmt.Errorf("the host path is empty")
+	}
+	if !strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "$") {
+		return fmt.Errorf("the container path '%s' has to be absolute (options like ':ro' aren't supported)", target)
+	}
+	return nil
+}
+
+// positions returns a function that finds the line and column of the keys on the document
+func positions(data []byte, format string) func(keys []string) (int, int) {
+	switch format {
+	case "yaml":
+		var root yaml.Node
+		if yaml.Unmarshal(data, &root) != nil || len(root.Content) == 0 {
+			break
+		}
+		return func(keys []string) (int, int) {
+			return yamlPosition(root.Content[0], keys)
+		}
+	case "json":
+		return func(keys []string) (int, int) {
+			return offsetPosition(data, jsonPosition(data, keys))
+		}
+	}
+	return func(keys []string) (int, int) {
+		return 0, 0
+	}
+}
+
+// jsonPosition returns the offset of the last key (or of the closest parent that exists)
+func jsonPosition(data []byte, keys []string) int {
+	position := skipSpace(data, 0)
+	for i, key := range keys {
+		if strings.HasPrefix(key, "[") {
+			index, _ := strconv.Atoi(strings.Trim(key, "[]"))
+			items := arrayItems(data, position)
+			if index >= len(items) {
+				return position
+			}
+			position = items[index]
+			continue
+		}
+
+		members, _, err := objectMembers(data, position)
+		if err != nil {
+			return position
+		}
+		found := false
+		for _, member := range members {
+			if member.key == key {
+				found = true
+				position = member.valueStart
+				if i == len(keys)-1 {
+					position = member.keyStart
+				}
+			}
+		}
+		if !found {
+			return position
+		}
+	}
+	return position
+}
+
+// arrayItems returns where the items of the list that starts at i start
+func arrayItems(data []byte, i int) []int {
+	items := []int{}
+	if i >= len(data) || data[i] != '[' {
+		return items
+	}
+
+	j := skipSpace(data, i+1)
+	for j < len(data) && data[j] != ']' {
+		items = append(items, j)
+		end, err := valueEnd(data, j)
+		if err != nil {
+			return items
+		}
+		j = skipSpace(data, end)
+		if j < len(data) && data[j] == ',' {
+			j = skipSpace(data, j+1)
+		}
+	}
+	return items
+}
+
+// offsetPosition converts an offset to a line and column
+func offsetPosition(data []byte, offset int) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(data) {
+		offset = len(data)
+	}
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	return line, offset - bytes.LastIndexByte(data[:offset], '\n')
+}
+
+// yamlPosition returns the line and column of the last key (or of the closest parent that exists)
+func yamlPosition(node *yaml.Node, keys []string) (int, int) {
+	for i, key := range keys {
+		switch node.Kind {
+		case yaml.SequenceNode:
+			index, _ := strconv.Atoi(strings.Trim(key, "[]"))
+			if index >= len(node.Content) {
+				return node.Line, node.Column
+			}
+			node = node.Content[index]
+		case yaml.MappingNode:
+			index := yamlKeyIndex(node, key)
+			if index < 0 {
+				return node.Line, node.Column
+			}
+			if i == len(keys)-1 {
+				return node.Content[index].Line, node.Content[index].Column
+			}
+			node = node.Content[index+1]
+		default:
+			return node.Line, node.Column
+		}
+	}
+	return node.Line, node.Column
+}
+
+// objectKeys returns the keys of the object in order
+func objectKeys(object map[string]interface{}) []string {
+	keys := []string{}
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isStringOrList returns true for a string or a list of strings
+func isStringOrList(value interface{}) bool {
+	if _, ok := value.(string); ok {
+		return true
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, item := range list {
+		if _, ok := item.(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// kindOf returns the JSON name of the type of the value
+func kindOf(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "a list"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return "null"
+}
+
+// containsString returns true if the list has the string
+func containsString(list []string, str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
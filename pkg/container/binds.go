@@ -17,11 +17,11 @@ package container
 import (
 	"fmt"
 	"os"
-	"strings"
 
 	"os/exec"
 
 	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/podman"
 	"github.com/kpango/glg"
 )
 
@@ -70,10 +70,10 @@ func mountXOrg(args []string) []string {
 }
 
 // Mounts /dev with the rslave option.
-func mountDev(cfg config.Podman) []string {
+func mountDev(kind string) []string {
 	// We mount /dev so we can access things like cameras and GPUs
 	// inside the container. See github.com/containers/podman/issues/5623.
-	if strings.Contains(cfg.Path, "docker") {
+	if kind != podman.KindPodman {
 		return []string{"-v=/dev:/dev:rslave"}
 	}
 
@@ -115,7 +115,7 @@ func getXDGRuntime() (string, bool) {
 }
 
 // Mounts all the required binds in the config file.
-func mountBindings(cfg config.Structure, xdgRuntime string) []string {
+func mountBindings(cfg config.Structure, kind string, xdgRuntime string) []string {
 	args := []string{}
 
 	os.Setenv("XDG_RUNTIME_DIR", xdgRuntime)
@@ -125,7 +125,7 @@ func mountBindings(cfg config.Structure, xdgRuntime string) []string {
 	}
 
 	if cfg.Container.Binds.Dev {
-		args = append(args, mountDev(cfg.Podman)...)
+		args = append(args, mountDev(kind)...)
 	}
 
 	args = append(args, fmt.Sprintf("-v=%s:%s:rslave", xdgRuntime, xdgRuntime))
@@ -0,0 +1,115 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/podman"
+)
+
+// HashLabel is the label that stores the hash of every config field used when creating the container.
+// Each field is also stored on its own label (HashLabel + "." + field) so we can tell which ones changed.
+const HashLabel = "develbox_config_hash"
+
+// driftFields are the config fields that only apply when the container is created, in the order they are reported
+var driftFields = []struct {
+	name  string
+	value func(cfg config.Structure) interface{}
+}{
+	{"image.uri", func(cfg config.Structure) interface{} { return cfg.Image.URI }},
+	{"image.on_creation", func(cfg config.Structure) interface{} { return cfg.Image.OnCreation }},
+	{"container.workdir", func(cfg config.Structure) interface{} { return cfg.Container.WorkDir }},
+	{"container.binds", func(cfg config.Structure) interface{} { return cfg.Container.Binds }},
+	{"container.ports", func(cfg config.Structure) interface{} { return cfg.Container.Ports }},
+	{"container.mounts", func(cfg config.Structure) interface{} { return cfg.Container.Mounts }},
+	{"container.shared_folders", func(cfg config.Structure) interface{} { return cfg.Container.SharedFolders }},
+	{"podman.args", func(cfg config.Structure) interface{} { return cfg.Podman.Args }},
+	{"podman.rootless", func(cfg config.Structure) interface{} { return cfg.Podman.Rootless }},
+	{"podman.privileged", func(cfg config.Structure) interface{} { return cfg.Podman.Privileged }},
+}
+
+// ConfigHashes returns a short hash for each config field that is used when creating the container
+func ConfigHashes(cfg config.Structure) map[string]string {
+	hashes := map[string]string{}
+	for _, field := range driftFields {
+		hashes[field.name] = hashValue(field.value(cfg))[:12]
+	}
+	return hashes
+}
+
+// ConfigHash returns a single hash of every config field that is used when creating the container
+func ConfigHash(cfg config.Structure) string {
+	hashes := ConfigHashes(cfg)
+
+	all := []string{}
+	for _, field := range driftFields {
+		all = append(all, field.name+"="+hashes[field.name])
+	}
+	return hashValue(all)
+}
+
+// hashLabels returns the "--label" arguments that store the config hashes
+func hashLabels(cfg config.Structure) []string {
+	hashes := ConfigHashes(cfg)
+
+	args := []string{"--label", fmt.Sprintf("%s=%s", HashLabel, ConfigHash(cfg))}
+	for _, field := range driftFields {
+		args = append(args, "--label", fmt.Sprintf("%s.%s=%s", HashLabel, field.name, hashes[field.name]))
+	}
+	return args
+}
+
+// Drift returns the config fields that changed since the container was created.
+//
+// known is false if the container was created by a version that didn't store the hashes.
+func Drift(info podman.ContainerInfo, cfg config.Structure) (fields []string, known bool) {
+	stored := info.Label(HashLabel)
+	if stored == "" {
+		return []string{}, false
+	}
+
+	fields = []string{}
+	if stored == ConfigHash(cfg) {
+		return fields, true
+	}
+
+	hashes := ConfigHashes(cfg)
+	for _, field := range driftFields {
+		if info.Label(HashLabel+"."+field.name) != hashes[field.name] {
+			fields = append(fields, field.name)
+		}
+	}
+	return fields, true
+}
+
+// hashValue returns the hex encoded SHA-256 of the JSON encoding of value
+func hashValue(value interface{}) string {
+	// A missing list or map is the same as an empty one
+	v := reflect.ValueOf(value)
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0 {
+		value = nil
+	}
+
+	// Maps are encoded with sorted keys, so the output is stable
+	data, _ := json.Marshal(value)
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
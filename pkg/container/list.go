@@ -0,0 +1,69 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"sort"
+	"time"
+
+	"github.com/kadmuffin/develbox/pkg/podman"
+)
+
+// Info is a develbox container found on the machine
+type Info struct {
+	Name          string    `json:"name"`
+	ID            string    `json:"id"`
+	Image         string    `json:"image"`
+	Status        string    `json:"status"`
+	Running       bool      `json:"running"`
+	ProjectPath   string    `json:"project_path"`
+	ProjectExists bool      `json:"project_exists"`
+	Version       string    `json:"version"`
+	Size          int64     `json:"size"`
+	Created       time.Time `json:"created"`
+	LastUsed      time.Time `json:"last_used"`
+}
+
+// List returns every develbox container (running or not) sorted by the last time they were used
+func List(pman podman.Engine) ([]Info, error) {
+	containers, err := pman.List(true, []string{"develbox_container=1"})
+	if err != nil {
+		return []Info{}, err
+	}
+
+	infos := []Info{}
+	for _, cont := range containers {
+		projectPath := cont.Label("develbox_project_path")
+
+		infos = append(infos, Info{
+			Name:          cont.Name,
+			ID:            cont.ID,
+			Image:         cont.Config.Image,
+			Status:        cont.State.Status,
+			Running:       cont.State.Running,
+			ProjectPath:   projectPath,
+			ProjectExists: projectPath != "" && FileExists(projectPath),
+			Version:       cont.Label("develbox_version"),
+			Size:          cont.SizeRw,
+			Created:       cont.Created,
+			LastUsed:      cont.LastUsed(),
+		})
+	}
+
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].LastUsed.After(infos[j].LastUsed)
+	})
+	return infos, nil
+}
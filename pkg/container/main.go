@@ -34,15 +34,29 @@ var PkgVersion = "latest"
 // DontStopOnFinish prevents the container from stopping after the create commands are ran
 var DontStopOnFinish = false
 
+// UseLockfile installs the versions recorded on .develbox/lock.json instead of the latest ones
+var UseLockfile = false
+
 // Create creates a container and runs the setupContainer function
 func Create(cfg config.Structure, deleteOld bool) error {
-	pman := podman.New(cfg.Podman.Path)
+	if UseLockfile && !config.FileExists(pkgm.LockFile) {
+		UseLockfile = false
+		return fmt.Errorf("can't create the container with the locked versions, %s doesn't exist", pkgm.LockFile)
+	}
+
+	pman := podman.FromConfig(cfg.Podman)
 	majorV, minorV, _, err := pman.Version()
 
 	if err != nil {
 		return glg.Errorf("Can't parse podman version: %s", err)
 	}
 
+	// Never delete (or reuse) the container of another project
+	if err := CheckCollision(pman, cfg.Container.Name); err != nil {
+		return err
+	}
+	MigrateName(pman, cfg)
+
 	if deleteOld {
 		glg.Debug("Deleting old container!")
 		pman.Remove([]string{cfg.Container.Name}, podman.Attach{})
@@ -52,8 +66,8 @@ func Create(cfg config.Structure, deleteOld bool) error {
 		return glg.Fail("Container already exists!")
 	}
 
-	if pman.IsDocker() {
-		glg.Warn("Be aware that while probably Docker works, it may have unknown issues.")
+	if pman.Kind() != podman.KindPodman {
+		glg.Warnf("Be aware that while probably %s works, it may have unknown issues.", pman.Kind())
 	}
 
 	user := os.Getenv("USER")
@@ -64,12 +78,12 @@ func Create(cfg config.Structure, deleteOld bool) error {
 	glg.Debugf("rootless is set to: %t", cfg.Podman.Rootless)
 	if cfg.Podman.Rootless {
 
-		if !pman.IsDocker() && uid != 0 {
+		if pman.Kind() == podman.KindPodman && uid != 0 {
 			// Remaps the container UID & GID so we can modify the /code folder
 			args = append(args, "--userns=keep-id")
 		}
 
-		if !pman.IsDocker() && (majorV >= 5 || majorV >= 4 && minorV >= 2) {
+		if pman.Kind() == podman.KindPodman && (majorV >= 5 || majorV >= 4 && minorV >= 2) {
 			// Setups a user account with the current account name
 			args = append(args, fmt.Sprintf("--passwd-entry=%s:*:$UID:0:develbox_container:/home/%s:/bin/sh", user, user))
 		} else {
@@ -79,7 +93,7 @@ func Create(cfg config.Structure, deleteOld bool) error {
 		// Mounts Wayland, XOrg, Pulseaudio, etc...
 		xdgRunt, found := getXDGRuntime()
 		if found {
-			args = append(args, mountBindings(cfg, xdgRunt)...)
+			args = append(args, mountBindings(cfg, pman.Kind(), xdgRunt)...)
 		} else {
 			glg.Warn("Can't mount $XDG_RUNTIME_DIR, directory doesn't exist!")
 		}
@@ -138,6 +152,8 @@ func Create(cfg config.Structure, deleteOld bool) error {
 	args = append(args, "--label", "develbox_container=1")
 	// Add DEVELBOX_PROJECT_PATH label to the container
 	args = append(args, "--label", fmt.Sprintf("develbox_project_path=\"%s\"", config.GetCurrentDirectory()))
+	// Add the config hashes so we can tell when the container is outdated
+	args = append(args, hashLabels(cfg)...)
 
 	// Mount the main folder and pass the image URI before the
 	// container is created.
@@ -166,12 +182,13 @@ func Create(cfg config.Structure, deleteOld bool) error {
 		}
 	}
 
-	setupContainer(&pman, cfg)
+	setupContainer(pman, cfg)
 
 	if !DontStopOnFinish {
 		pman.Stop([]string{cfg.Container.Name}, podman.Attach{Stderr: true})
 	}
 	DontStopOnFinish = false
+	UseLockfile = false
 
 	if cfg.Podman.AutoCommit {
 		glg.Warn("Auto commit feature is enabled, deleting old image (if exists) and commiting new one.")
@@ -194,7 +211,7 @@ func Create(cfg config.Structure, deleteOld bool) error {
 }
 
 // setupContainer installs the packages and runs the onCreation & onFinish commands
-func setupContainer(pman *podman.Podman, cfg config.Structure) {
+func setupContainer(pman podman.Engine, cfg config.Structure) {
 	// Runs commands that should be ran just
 	// after the container was created
 	err := RunCommandList(cfg.Container.Name,
@@ -218,33 +235,72 @@ func setupContainer(pman *podman.Podman, cfg config.Structure) {
 	var goInstalled bool
 
 	// Check if cfg.Packages or cfg.DevPackages contain go
-	if !Contains(cfg.Packages, "go") && !Contains(cfg.DevPackages, "go") {
+	if !pkgm.ContainsPackage(cfg.Packages, "go") && !pkgm.ContainsPackage(cfg.DevPackages, "go") {
 		fmt.Println("> Installing Go for develbox experimental features")
 		opert := pkgm.NewOperation("add", []string{"go"}, []string{}, true)
-		cmd, _ := opert.ProcessCmd(&cfg, podman.Attach{
+		cmd, err := opert.ProcessCmd(&cfg, podman.Attach{
 			Stdin:  true,
 			Stdout: true,
 			Stderr: true,
 		})
+		if err == nil {
+			err = cmd.Run()
+		}
 
-		if cmd.Run() != nil {
-			glg.Warn("Couldn't install Go, some features may not work")
+		if err != nil {
+			glg.Warnf("Couldn't install Go, some features may not work. %s", err)
 		} else {
 			goInstalled = true
 		}
 	}
 
+	// Records what was installed, so entering the container only installs what changed
+	state := pkgm.State{Packages: []string{}, UserPackages: []string{}}
+
 	if len(cfg.Packages)+len(cfg.DevPackages) > 0 {
-		installPkgs(pman, cfg, append(cfg.Packages, cfg.DevPackages...), true)
+		pkgs := append(append([]string{}, cfg.Packages...), cfg.DevPackages...)
+		if err := installPkgs(pman, cfg, pkgs, true); err != nil {
+			glg.Warnf("Couldn't install the packages, they will be installed when entering the container. %s", err)
+		} else {
+			state.Packages = pkgs
+		}
 	}
 
 	if len(cfg.UserPkgs.Packages)+len(cfg.UserPkgs.DevPackages) > 0 && cfg.Podman.Rootless {
-		err := installPkgs(pman, cfg, append(cfg.UserPkgs.Packages, cfg.UserPkgs.DevPackages...), false)
+		pkgs := append(append([]string{}, cfg.UserPkgs.Packages...), cfg.UserPkgs.DevPackages...)
+		err := installPkgs(pman, cfg, pkgs, false)
 
 		if err != nil {
 			pman.Remove([]string{cfg.Container.Name}, podman.Attach{Stderr: true})
 			glg.Fatalf("Something went wrong while installing the specified packages. %s", err)
 		}
+		state.UserPackages = pkgs
+	}
+
+	for _, name := range cfg.PkgManagerNames() {
+		pkgManager := cfg.Image.PkgManagers[name]
+		pkgs := append(append([]string{}, pkgManager.Packages...), pkgManager.DevPackages...)
+		if len(pkgs) == 0 {
+			continue
+		}
+		if err := installManagerPkgs(cfg, name, pkgs); err != nil {
+			glg.Warnf("Couldn't install the packages of %s: %s", name, err)
+			continue
+		}
+		if state.Managers == nil {
+			state.Managers = map[string][]string{}
+		}
+		state.Managers[name] = pkgs
+	}
+
+	if err := pkgm.WriteState(&cfg, state); err != nil {
+		glg.Warnf("Couldn't save the installed packages: %s", err)
+	}
+
+	if pkgm.SupportsQuery(&cfg) {
+		if err := pkgm.UpdateLock(&cfg); err != nil {
+			glg.Warnf("Couldn't update %s: %s", pkgm.LockFile, err)
+		}
 	}
 
 	if goInstalled {
@@ -275,19 +331,80 @@ func setupContainer(pman *podman.Podman, cfg config.Structure) {
 	}
 }
 
-func installPkgs(pman *podman.Podman, cfg config.Structure, pkgs []string, root bool) error {
+func installPkgs(pman podman.Engine, cfg config.Structure, pkgs []string, root bool) error {
 	opert := pkgm.NewOperation("add", pkgs, []string{}, true)
 	opert.UserOperation = !root
-	cmd, _ := opert.ProcessCmd(&cfg, podman.Attach{Stdin: true, Stdout: true, Stderr: true})
+	return runInstall(cfg, opert)
+}
+
+// installManagerPkgs installs the packages of one of the package managers on image.pkgmanagers
+func installManagerPkgs(cfg config.Structure, manager string, pkgs []string) error {
+	opert := pkgm.NewOperation("add", pkgs, []string{}, true)
+	if err := opert.SetManager(&cfg, manager); err != nil {
+		return err
+	}
+	return runInstall(cfg, opert)
+}
+
+// runInstall runs an add operation, using the versions of the lock file if UseLockfile is set
+func runInstall(cfg config.Structure, opert pkgm.Operation) error {
+	if UseLockfile {
+		opert.Packages = lockedPkgs(cfg, opert)
+	}
+
+	cmd, err := opert.ProcessCmd(&cfg, podman.Attach{Stdin: true, Stdout: true, Stderr: true})
+	if err != nil {
+		return err
+	}
 	return cmd.Run()
 }
 
+// lockedPkgs pins the packages of the operation to the versions on the lock file
+func lockedPkgs(cfg config.Structure, opert pkgm.Operation) []string {
+	pkgs := opert.Packages
+	lock, err := pkgm.ReadLock()
+	if err != nil {
+		glg.Warnf("Can't read %s, installing the latest versions. %s", pkgm.LockFile, err)
+		return pkgs
+	}
+
+	versions := lock.Packages
+	if opert.UserOperation {
+		versions = lock.UserPackages
+	}
+	if opert.Manager != "" {
+		versions = lock.Managers[opert.Manager]
+	}
+
+	pkgManager, err := cfg.GetPkgManager(opert.Manager)
+	if err != nil {
+		glg.Warnf("%s, installing the latest versions", err)
+		return pkgs
+	}
+	driver, err := pkgm.GetDriver(&pkgManager)
+	if err != nil {
+		glg.Warnf("%s, installing the latest versions", err)
+		return pkgs
+	}
+
+	if driver.Modifiers["pin"] == "" {
+		glg.Warn("The package manager doesn't define a pin modifier, installing the latest versions")
+		return pkgs
+	}
+
+	pinned, missing := pkgm.PinPackages(pkgs, versions)
+	if len(missing) > 0 {
+		glg.Warnf("These packages aren't on %s, installing the latest versions: %v", pkgm.LockFile, missing)
+	}
+	return pinned
+}
+
 // DontAttachEnter is a flag that tells the enter command to not attach to the container (for the enter command)
 var DontAttachEnter = false
 
 // Enter runs a shell in the container and creates a pipe for package installations.
 func Enter(cfg config.Structure, root bool) error {
-	pman := podman.New(cfg.Podman.Path)
+	pman := podman.FromConfig(cfg.Podman)
 
 	attach := podman.Attach{
 		Stdin:     !DontAttachEnter,
@@ -309,11 +426,9 @@ func Enter(cfg config.Structure, root bool) error {
 	return cmd.Run()
 }
 
-// InstallAndEnter install the packages and runs a shell in the container
+// InstallAndEnter installs the packages that changed since the last time and runs a shell in the container
 func InstallAndEnter(cfg config.Structure, root bool) error {
-	pman := podman.New(cfg.Podman.Path)
-
-	err := installPkgs(&pman, cfg, append(cfg.Packages, cfg.DevPackages...), true)
+	err := pkgm.Reconcile(&cfg)
 	if err != nil {
 		return glg.Errorf("Couldn't install packages. %s", err)
 	}
@@ -0,0 +1,112 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/podman"
+	"github.com/kpango/glg"
+)
+
+// CollisionError is returned when the container name is already used by another project
+type CollisionError struct {
+	// Name is the container name
+	Name string
+	// ProjectPath is the project that owns the container
+	ProjectPath string
+}
+
+func (e *CollisionError) Error() string {
+	return fmt.Sprintf("container %s belongs to another project (%s)", e.Name, e.ProjectPath)
+}
+
+// ErrNoContainer is returned by Open when the container of the project doesn't exist
+var ErrNoContainer = errors.New("container does not exist")
+
+// CheckCollision returns a CollisionError if a container with the name exists and its develbox_project_path label points to another project
+func CheckCollision(pman podman.Engine, name string) error {
+	info, err := pman.Inspect(name)
+	if err == podman.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return checkOwner(info, name)
+}
+
+// checkOwner returns a CollisionError if the develbox_project_path label of the container points to another project
+func checkOwner(info podman.ContainerInfo, name string) error {
+	projectPath := info.Label("develbox_project_path")
+	if projectPath != "" && projectPath != config.GetCurrentDirectory() {
+		return &CollisionError{Name: name, ProjectPath: projectPath}
+	}
+	return nil
+}
+
+// Open returns the container of the project, used by the commands that work on an existing container.
+//
+// Returns ErrNoContainer if it doesn't exist (after trying MigrateName), and a CollisionError if it belongs to another project.
+func Open(pman podman.Engine, cfg config.Structure) (podman.ContainerInfo, error) {
+	info, err := pman.Inspect(cfg.Container.Name)
+	if err == podman.ErrNotFound && migrateName(pman, cfg) {
+		info, err = pman.Inspect(cfg.Container.Name)
+	}
+	if err == podman.ErrNotFound {
+		return info, ErrNoContainer
+	}
+	if err != nil {
+		return info, err
+	}
+	return info, checkOwner(info, cfg.Container.Name)
+}
+
+// MigrateName renames the container created by older versions (named after the project folder) to the name derived from the full path.
+//
+// Only applies when the config doesn't set a name, and the old container belongs to this project.
+func MigrateName(pman podman.Engine, cfg config.Structure) {
+	if !pman.Exists(cfg.Container.Name) {
+		migrateName(pman, cfg)
+	}
+}
+
+// migrateName is MigrateName when the container doesn't exist, returns true if it was renamed
+func migrateName(pman podman.Engine, cfg config.Structure) bool {
+	legacy := config.LegacyName()
+	if cfg.Container.Name != config.DefaultName() || cfg.Container.Name == legacy {
+		return false
+	}
+	info, err := pman.Inspect(legacy)
+	if err != nil {
+		return false
+	}
+
+	if err := checkOwner(info, legacy); err != nil {
+		glg.Warnf("Not migrating the old container name: %s", err)
+		return false
+	}
+
+	glg.Infof("Renaming container %s to %s (names are now derived from the project path).", legacy, cfg.Container.Name)
+	err = pman.RawCommand([]string{"rename", legacy, cfg.Container.Name}, podman.Attach{Stderr: true}).Run()
+	if err != nil {
+		glg.Warnf("Couldn't rename the container: %s", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,204 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+	globalData "github.com/kadmuffin/develbox/pkg/global"
+	"github.com/kadmuffin/develbox/pkg/podman"
+	"github.com/kpango/glg"
+)
+
+// Orphan is something that develbox left behind and can be removed
+type Orphan struct {
+	// Kind is "container", "image" or "shared"
+	Kind string `json:"kind"`
+	// Ref is the name of the container, the ID of the image or the path of the shared folder
+	Ref string `json:"ref"`
+	// Reason explains why it's considered an orphan
+	Reason string `json:"reason"`
+	// Size is the disk space (in bytes) that is reclaimed when removed
+	Size int64 `json:"size"`
+}
+
+// FindOrphans searches for:
+//   - containers whose project path doesn't exist anymore
+//   - images commited by AutoCommit that belong to a missing project and aren't used by a container
+//   - folders under $XDG_DATA_HOME/develbox/shared that no container (besides the orphans) mounts, only with shared
+//
+// Containers are deleted by auto_delete and trash, so a shared folder is kept while the config of a known project
+// (the current one or one on the develbox_project_path labels) uses it.
+func FindOrphans(pman podman.Engine, shared bool) ([]Orphan, error) {
+	containers, err := pman.List(true, []string{"develbox_container=1"})
+	if err != nil {
+		return []Orphan{}, err
+	}
+
+	orphans := []Orphan{}
+	usedImages := map[string]bool{}
+	usedFolders := map[string]bool{}
+	projects := map[string]bool{config.GetCurrentDirectory(): true}
+	sharedRoot := globalData.GetSharedFolder()
+
+	for _, cont := range containers {
+		projectPath := cont.Label("develbox_project_path")
+		if projectPath != "" && !FileExists(projectPath) {
+			orphans = append(orphans, Orphan{
+				Kind:   "container",
+				Ref:    cont.Name,
+				Reason: "project " + projectPath + " doesn't exist",
+				Size:   cont.SizeRw,
+			})
+			continue
+		}
+		if projectPath != "" {
+			projects[projectPath] = true
+		}
+
+		usedImages[strings.TrimPrefix(cont.ImageID, "sha256:")] = true
+		for _, mount := range cont.Mounts {
+			if folder := sharedSubfolder(sharedRoot, mount.Source); folder != "" {
+				usedFolders[folder] = true
+			}
+		}
+	}
+
+	images, err := pman.ListImages([]string{"develbox_container=1"})
+	if err != nil {
+		glg.Warnf("Can't list the commited images: %s", err)
+	}
+	for _, img := range images {
+		projectPath := img.Label("develbox_project_path")
+		if projectPath != "" && FileExists(projectPath) {
+			projects[projectPath] = true
+		}
+		if usedImages[strings.TrimPrefix(img.ID, "sha256:")] || projectPath == "" || FileExists(projectPath) {
+			continue
+		}
+
+		orphans = append(orphans, Orphan{
+			Kind:   "image",
+			Ref:    img.ID,
+			Reason: "commited for " + projectPath + " which doesn't exist",
+			Size:   img.Size,
+		})
+	}
+
+	if !shared {
+		return orphans, nil
+	}
+	for project := range projects {
+		tags, err := sharedTags(project)
+		if err != nil {
+			glg.Warnf("Not pruning shared folders, can't read the config of %s: %s", project, err)
+			return orphans, nil
+		}
+		for _, tag := range tags {
+			usedFolders[globalData.GetTaggedFolder(tag)] = true
+		}
+	}
+
+	folders, err := os.ReadDir(sharedRoot)
+	if err != nil && !os.IsNotExist(err) {
+		return orphans, err
+	}
+	for _, folder := range folders {
+		path := filepath.Join(sharedRoot, folder.Name())
+		if !folder.IsDir() || usedFolders[path] {
+			continue
+		}
+
+		orphans = append(orphans, Orphan{
+			Kind:   "shared",
+			Ref:    path,
+			Reason: "not used by any container or project",
+			Size:   folderSize(path),
+		})
+	}
+
+	return orphans, nil
+}
+
+// RemoveOrphans deletes the orphans and returns the disk space reclaimed
+func RemoveOrphans(pman podman.Engine, orphans []Orphan) (int64, error) {
+	var reclaimed int64
+	for _, orphan := range orphans {
+		var err error
+		switch orphan.Kind {
+		case "container":
+			err = pman.Remove([]string{orphan.Ref}, podman.Attach{Stderr: true})
+		case "image":
+			err = pman.RawCommand([]string{"rmi", orphan.Ref}, podman.Attach{Stderr: true}).Run()
+		case "shared":
+			err = os.RemoveAll(orphan.Ref)
+		}
+
+		if err != nil {
+			return reclaimed, glg.Errorf("Couldn't remove %s %s. %s", orphan.Kind, orphan.Ref, err)
+		}
+		reclaimed += orphan.Size
+	}
+	return reclaimed, nil
+}
+
+// sharedTags returns the shared folders on the config of a project (with its global and local layers).
+// Only the ones of the global config if the project doesn't have one.
+func sharedTags(project string) ([]string, error) {
+	tags := []string{}
+	cfg, err := config.ReadProject(project)
+	if errors.Is(err, fs.ErrNotExist) {
+		if !FileExists(config.GlobalPath()) {
+			return tags, nil
+		}
+		cfg, _, err = config.ReadFile(config.GlobalPath())
+	}
+	if err != nil {
+		return tags, err
+	}
+	for tag := range cfg.Container.SharedFolders {
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// sharedSubfolder returns the first level folder under root that contains path, or an empty string if it's outside root
+func sharedSubfolder(root string, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return filepath.Join(root, strings.Split(rel, string(filepath.Separator))[0])
+}
+
+// folderSize returns the size of every file inside path
+func folderSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil && !entry.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
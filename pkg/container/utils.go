@@ -83,7 +83,7 @@ func processMounts(cfg config.Structure) (result []string) {
 }
 
 // RunCommandList loops through the commands list and runs each one separately
-func RunCommandList(name string, commands []string, pman *podman.Podman, root bool, attach podman.Attach) error {
+func RunCommandList(name string, commands []string, pman podman.Engine, root bool, attach podman.Attach) error {
 	for _, command := range commands {
 		if err := pman.Exec([]string{name, podman.ReplaceEnvVars(command)}, map[string]string{}, true, root, attach).Run(); err != nil {
 			return err
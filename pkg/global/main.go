@@ -33,16 +33,21 @@ func CreateTaggedFolder(tag string) error {
 		glg.Fatalf("Invalid tag name, only alphanumeric characters and underscores are allowed. Got %s", tag)
 	}
 
-	err := CreateFolder(GetDataHome() + "/develbox/shared/" + tag)
+	err := CreateFolder(GetTaggedFolder(tag))
 	if err != nil && !os.IsExist(err) {
 		glg.Fatal(err)
 	}
 	return nil
 }
 
+// GetSharedFolder returns the path to the folder that contains every shared folder ($XDG_DATA_HOME/develbox/shared)
+func GetSharedFolder() string {
+	return GetDataHome() + "/develbox/shared"
+}
+
 // GetTaggedFolder returns the path to a shared folder at $XDG_DATA_HOME/develbox/shared/<name>
 func GetTaggedFolder(tag string) string {
-	return GetDataHome() + "/develbox/shared/" + tag
+	return GetSharedFolder() + "/" + tag
 }
 
 // CreateAndGet creates a new shared folder and returns the path to it
@@ -0,0 +1,608 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkgm
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/pkgm/template"
+)
+
+// CustomDriver is the driver that runs the operations defined on the config
+const CustomDriver = "custom"
+
+// Driver describes how to talk to a package manager
+type Driver struct {
+	// Name is the value of "driver" on the config
+	Name string
+	// Operations are the base commands of each operation, the flags and packages are added at the end
+	Operations map[string]string
+	// NonInteractive is the flag that skips the confirmations, it's added after the executable
+	NonInteractive string
+	// Modifiers are applied to the package names of an operation (see config.PackageManager)
+	Modifiers map[string]string
+	// CacheDirs are the folders where the package manager keeps the downloaded packages
+	CacheDirs []string
+	// ParseQuery reads the output of the query operation, ParseVersions is used when nil
+	ParseQuery func(output string, pkgs []string) map[string]string
+	// QueryListsAll is true if the query operation prints every installed package, so the packages aren't passed to it
+	QueryListsAll bool
+	// ParseSearch reads the output of the search operation
+	ParseSearch func(output string) []SearchResult
+	// SearchShowsInstalled is true if the search results tell which packages are installed, if not, the query operation is used
+	SearchShowsInstalled bool
+	// SearchNoMatch is the exit code of the search operation when nothing matches (0 if it doesn't fail)
+	SearchNoMatch int
+	// Constraints is true if the package manager understands version constraints like "numpy>=1.20" (see Spec.Operator)
+	Constraints bool
+	// ParseInstalled reads the output of the installed operation, the first word of every line is used when nil
+	ParseInstalled func(output string) []string
+	// ParseOutdated reads the output of the outdated operation, "<name> <candidate>" lines are expected when nil
+	ParseOutdated func(output string) map[string]string
+
+	// templates is true for the custom driver, the operations are templates (see pkg/pkgm/template)
+	templates bool
+}
+
+// operationTypes are the operations a driver can define
+var operationTypes = []string{"add", "del", "update", "upgrade", "search", "clean", "query", "installed", "outdated"}
+
+// confirmOperations are the operations that ask for confirmation
+var confirmOperations = []string{"add", "del", "update", "upgrade", "clean"}
+
+// rpmQuery prints the installed packages on rpm based distros
+const rpmQuery = "rpm -q --qf '%{NAME} %{VERSION}-%{RELEASE}\\n'"
+
+// drivers are the built-in package managers
+var drivers = map[string]Driver{
+	"apt": {
+		Name: "apt",
+		Operations: map[string]string{
+			"add":       "apt-get install",
+			"del":       "apt-get remove",
+			"update":    "apt-get update",
+			"upgrade":   "apt-get upgrade",
+			"search":    "apt search",
+			"clean":     "apt-get clean",
+			"query":     "dpkg-query -W -f='${Package} ${Version}\\n'",
+			"installed": "apt-mark showmanual",
+			"outdated":  "apt list --upgradable",
+		},
+		NonInteractive:       "-y",
+		Modifiers:            map[string]string{"pin": "{package}={version}", "repo": "{package}/{repo}"},
+		CacheDirs:            []string{"/var/cache/apt"},
+		ParseSearch:          parseAptSearch,
+		SearchShowsInstalled: true,
+		ParseOutdated:        parseAptOutdated,
+	},
+	"dnf": {
+		Name: "dnf",
+		Operations: map[string]string{
+			"add":       "dnf install",
+			"del":       "dnf remove",
+			"update":    "dnf makecache",
+			"upgrade":   "dnf upgrade",
+			"search":    "dnf search",
+			"clean":     "dnf clean all",
+			"query":     rpmQuery,
+			"installed": "dnf repoquery --userinstalled --qf '%{name}'",
+			"outdated":  "dnf check-update",
+		},
+		NonInteractive: "-y",
+		Modifiers:      map[string]string{"pin": "{package}-{version}"},
+		CacheDirs:      []string{"/var/cache/dnf"},
+		ParseSearch:    parseDnfSearch,
+		SearchNoMatch:  1,
+		ParseOutdated:  parseDnfOutdated,
+	},
+	"apk": {
+		Name: "apk",
+		Operations: map[string]string{
+			"add":       "apk add",
+			"del":       "apk del",
+			"update":    "apk update",
+			"upgrade":   "apk upgrade",
+			"search":    "apk search -v",
+			"query":     "apk list -I",
+			"installed": "cat /etc/apk/world",
+			"outdated":  "apk version -l '<'",
+		},
+		Modifiers:      map[string]string{"pin": "{package}={version}", "repo": "{package}@{repo}"},
+		CacheDirs:      []string{"/var/cache/apk"},
+		ParseSearch:    parseApkSearch,
+		ParseOutdated:  parseApkOutdated,
+		ParseInstalled: parseApkWorld,
+	},
+	"pacman": {
+		Name: "pacman",
+		Operations: map[string]string{
+			"add":       "pacman -S",
+			"del":       "pacman -R",
+			"update":    "pacman -Syu",
+			"upgrade":   "pacman -Syu",
+			"search":    "pacman -Ss",
+			"clean":     "pacman -Sc",
+			"query":     "pacman -Q",
+			"installed": "pacman -Qqe",
+			"outdated":  "pacman -Qu",
+		},
+		NonInteractive:       "--noconfirm",
+		Modifiers:            map[string]string{"repo": "{repo}/{package}"},
+		CacheDirs:            []string{"/var/cache/pacman/pkg"},
+		ParseSearch:          parsePacmanSearch,
+		SearchNoMatch:        1,
+		SearchShowsInstalled: true,
+		ParseOutdated:        parsePacmanOutdated,
+	},
+	"zypper": {
+		Name: "zypper",
+		Operations: map[string]string{
+			"add":       "zypper install",
+			"del":       "zypper remove",
+			"update":    "zypper refresh",
+			"upgrade":   "zypper update",
+			"search":    "zypper search",
+			"clean":     "zypper clean --all",
+			"query":     rpmQuery,
+			"installed": "zypper -q packages --installed-only --userinstalled",
+			"outdated":  "zypper -q list-updates",
+		},
+		NonInteractive:       "-n",
+		Modifiers:            map[string]string{"pin": "{package}={version}"},
+		CacheDirs:            []string{"/var/cache/zypp"},
+		ParseSearch:          parseZypperSearch,
+		SearchNoMatch:        104,
+		SearchShowsInstalled: true,
+		ParseOutdated:        parseZypperOutdated,
+		ParseInstalled:       parseZypperInstalled,
+	},
+	"xbps": {
+		Name: "xbps",
+		Operations: map[string]string{
+			"add":     "xbps-install",
+			"del":     "xbps-remove",
+			"update":  "xbps-install -S",
+			"upgrade": "xbps-install -Su",
+			"search":  "xbps-query -Rs",
+			"clean":   "xbps-remove -O",
+			// xbps-query can't print more than one package, so we list all of them
+			"query":     "xbps-query -l",
+			"installed": "xbps-query -m",
+			"outdated":  "xbps-install -Sun",
+		},
+		NonInteractive:       "-y",
+		Modifiers:            map[string]string{},
+		CacheDirs:            []string{"/var/cache/xbps"},
+		ParseQuery:           parseXbps,
+		ParseSearch:          parseXbpsSearch,
+		SearchShowsInstalled: true,
+		ParseOutdated:        parseXbpsOutdated,
+		ParseInstalled:       parseNameVersions,
+	},
+	"nix": {
+		Name: "nix",
+		Operations: map[string]string{
+			"add":       "nix-env -iA",
+			"del":       "nix-env -e",
+			"update":    "nix-channel --update",
+			"upgrade":   "nix-env -u",
+			"search":    "nix-env -qaP --description",
+			"clean":     "nix-collect-garbage",
+			"query":     "nix-env -q",
+			"installed": "nix-env -q",
+			"outdated":  "nix-env -qc",
+		},
+		Modifiers:      map[string]string{"add": "nixpkgs.{package}"},
+		ParseSearch:    parseNixSearch,
+		SearchNoMatch:  1,
+		ParseOutdated:  parseNixOutdated,
+		ParseInstalled: parseNameVersions,
+	},
+	"pip": {
+		Name: "pip",
+		Operations: map[string]string{
+			"add":       "pip install",
+			"del":       "pip uninstall -y",
+			"clean":     "pip cache purge",
+			"query":     "pip list --format=freeze",
+			"installed": "pip list --not-required --format=freeze",
+			"outdated":  "pip list --outdated",
+		},
+		Modifiers:      map[string]string{"pin": "{package}=={version}"},
+		Constraints:    true,
+		QueryListsAll:  true,
+		ParseQuery:     parsePipFreeze,
+		ParseInstalled: parsePipInstalled,
+		ParseOutdated:  parseTableOutdated(0, 2),
+	},
+	"npm": {
+		Name: "npm",
+		Operations: map[string]string{
+			"add":       "npm install -g",
+			"del":       "npm uninstall -g",
+			"upgrade":   "npm update -g",
+			"search":    "npm search",
+			"clean":     "npm cache clean --force",
+			"query":     "npm ls -g --depth=0",
+			"installed": "npm ls -g --depth=0",
+			"outdated":  "npm outdated -g",
+		},
+		Modifiers:      map[string]string{"pin": "{package}@{version}"},
+		ParseQuery:     parseNpmTree,
+		ParseInstalled: parseNpmInstalled,
+		ParseOutdated:  parseTableOutdated(0, 3),
+	},
+	"cargo": {
+		Name: "cargo",
+		Operations: map[string]string{
+			"add":       "cargo install",
+			"del":       "cargo uninstall",
+			"search":    "cargo search",
+			"query":     "cargo install --list",
+			"installed": "cargo install --list",
+		},
+		Modifiers:      map[string]string{"pin": "{package}@{version}"},
+		QueryListsAll:  true,
+		ParseQuery:     parseCargoList,
+		ParseInstalled: parseCargoInstalled,
+	},
+}
+
+func init() {
+	config.DriverNames = append(Drivers(), CustomDriver)
+}
+
+// Drivers returns the names of the built-in drivers
+func Drivers() []string {
+	names := []string{}
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetDriver returns the driver selected on the config. The modifiers of the config are added to the ones of the driver.
+func GetDriver(cfg *config.PackageManager) (Driver, error) {
+	if cfg.Driver == "" || cfg.Driver == CustomDriver {
+		return customDriver(cfg), nil
+	}
+
+	base, ok := drivers[cfg.Driver]
+	if !ok {
+		return Driver{}, fmt.Errorf("unknown package manager driver '%s', use one of %v or '%s'", cfg.Driver, Drivers(), CustomDriver)
+	}
+
+	driver := base
+	driver.Modifiers = map[string]string{}
+	for op, modifier := range base.Modifiers {
+		driver.Modifiers[op] = modifier
+	}
+	for op, modifier := range cfg.Modifiers {
+		driver.Modifiers[op] = modifier
+	}
+	return driver, nil
+}
+
+// managerDriver returns the driver of the package manager with that name ("" for the system one)
+func managerDriver(cfg *config.Structure, manager string) (Driver, error) {
+	pkgManager, err := cfg.GetPkgManager(manager)
+	if err != nil {
+		return Driver{}, err
+	}
+	return GetDriver(&pkgManager)
+}
+
+// customDriver creates a driver from the operations of the config
+func customDriver(cfg *config.PackageManager) Driver {
+	ops := cfg.Operations
+	return Driver{
+		Name: CustomDriver,
+		Operations: map[string]string{
+			"add":       ops.Add,
+			"del":       ops.Del,
+			"update":    ops.Upd,
+			"upgrade":   ops.Upg,
+			"search":    ops.Srch,
+			"clean":     ops.Clean,
+			"query":     ops.Query,
+			"installed": ops.Installed,
+			"outdated":  ops.Outdated,
+		},
+		Modifiers: cfg.Modifiers,
+		templates: true,
+	}
+}
+
+// Supports returns true if the driver has a command for the operation
+func (d Driver) Supports(opType string) bool {
+	if opType == "clean" && len(d.CacheDirs) > 0 {
+		return true
+	}
+	return d.Operations[opType] != ""
+}
+
+// Command returns the string that will be send to the container. For example: "apt-get -y install vim".
+func (d Driver) Command(e *Operation) (string, error) {
+	if !ContainsString(operationTypes, e.Type) {
+		return "", fmt.Errorf("couldn't find the key '%s' on the list of supported operations", e.Type)
+	}
+	base := d.Operations[e.Type]
+
+	packages, err := d.renderPackages(e)
+	if err != nil {
+		return "", err
+	}
+	if e.Type == "query" && d.QueryListsAll {
+		packages = []string{}
+	}
+	flags := []string{}
+	for _, flag := range e.Flags {
+		flags = append(flags, template.Quote(flag))
+	}
+
+	if d.templates {
+		tmpl, err := template.Parse(base)
+		if err != nil {
+			return "", err
+		}
+		vars := e.vars()
+		vars["packages"] = template.SafeWords(packages)
+		vars["flags"] = template.SafeWords(flags)
+		vars["args"] = template.SafeWords(append(flags, packages...))
+		return tmpl.Execute(vars)
+	}
+
+	// Without a clean command, we just remove the cache
+	if base == "" && e.Type == "clean" && len(d.CacheDirs) > 0 {
+		return fmt.Sprintf("rm -rf %s/*", strings.Join(d.CacheDirs, "/* ")), nil
+	}
+	if base == "" {
+		return "", fmt.Errorf("the %s driver doesn't support the '%s' operation", d.Name, e.Type)
+	}
+
+	parts := strings.Fields(base)
+	if e.AutoInstall && d.NonInteractive != "" && ContainsString(confirmOperations, e.Type) {
+		parts = append([]string{parts[0], d.NonInteractive}, parts[1:]...)
+	}
+	parts = append(parts, flags...)
+	parts = append(parts, packages...)
+	return strings.Join(parts, " "), nil
+}
+
+// renderPackages applies the modifier of the operation to every package, the result is already quoted
+func (d Driver) renderPackages(e *Operation) ([]string, error) {
+	var tmpl *template.Template
+	if modifier := d.Modifiers[e.Type]; modifier != "" {
+		var err error
+		tmpl, err = template.Parse(modifier)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	packages := []string{}
+	for _, pkg := range e.Packages {
+		pkg, err := d.RenderSpec(ParseSpec(pkg), e)
+		if err != nil {
+			return nil, err
+		}
+		if tmpl == nil {
+			packages = append(packages, template.Quote(pkg))
+			continue
+		}
+
+		vars := e.vars()
+		vars["package"] = template.Word(pkg)
+		vars["version"] = template.Word("")
+		vars["repo"] = template.Word("")
+		rendered, err := tmpl.Execute(vars)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, rendered)
+	}
+	return packages, nil
+}
+
+// RenderSpec writes the package the way the package manager understands it, using the "pin" modifier for the version
+// and the "repo" modifier for the repository. Only the add operation uses them, the rest just get the name.
+// Version constraints are written as they are, only if the package manager understands them.
+func (d Driver) RenderSpec(spec Spec, e *Operation) (string, error) {
+	pkg := spec.Name
+	if e.Type != "add" {
+		return pkg, nil
+	}
+
+	if spec.Operator != "" {
+		if !d.Constraints {
+			return "", fmt.Errorf("the %s driver doesn't understand version constraints like '%s' (use %s@<version> for a specific version)", d.Name, spec.Operator, spec.Name)
+		}
+		spec.Name, spec.Version = spec.Name+spec.Operator+spec.Version, ""
+		pkg = spec.Name
+	}
+
+	for _, part := range []struct{ modifier, value, name string }{
+		{"pin", spec.Version, "version"},
+		{"repo", spec.Repo, "repo"},
+	} {
+		if part.value == "" {
+			continue
+		}
+		modifier := d.Modifiers[part.modifier]
+		if modifier == "" {
+			return "", fmt.Errorf("the %s driver can't install a specific %s of '%s' (there's no '%s' modifier)", d.Name, part.name, spec.Name, part.modifier)
+		}
+		tmpl, err := template.Parse(modifier)
+		if err != nil {
+			return "", err
+		}
+
+		// The result is quoted later, as any other package
+		vars := e.vars()
+		vars["package"] = template.Word(pkg)
+		vars["version"] = template.Word(spec.Version)
+		vars["repo"] = template.Word(spec.Repo)
+		pkg, err = tmpl.ExecuteRaw(vars)
+		if err != nil {
+			return "", err
+		}
+	}
+	return pkg, nil
+}
+
+// vars returns the variables shared by the operations and modifiers
+func (e *Operation) vars() template.Vars {
+	return template.Vars{
+		"confirm": template.Flag(e.AutoInstall),
+		"user":    template.Flag(e.UserOperation),
+		"arch":    template.Word(Arch()),
+	}
+}
+
+// Arch returns the architecture of the packages, using the names of uname
+func Arch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	case "386":
+		return "i686"
+	case "arm":
+		return "armv7l"
+	default:
+		return runtime.GOARCH
+	}
+}
+
+// Parse reads the output of the query operation
+func (d Driver) Parse(output string, pkgs []string) map[string]string {
+	if d.ParseQuery != nil {
+		return d.ParseQuery(output, pkgs)
+	}
+	return ParseVersions(output, pkgs)
+}
+
+// parseXbps reads the output of "xbps-query -l" ("ii <name>-<version> <description>")
+func parseXbps(output string, pkgs []string) map[string]string {
+	lines := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 1 {
+			lines = append(lines, fields[1])
+		}
+	}
+	return ParseVersions(strings.Join(lines, "\n"), pkgs)
+}
+
+// parsePipFreeze reads the output of "pip list --format=freeze" ("requests==2.31.0"), pip doesn't care about the case of the names
+func parsePipFreeze(output string, pkgs []string) map[string]string {
+	versions := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		name, version, found := strings.Cut(strings.TrimSpace(line), "==")
+		if !found {
+			continue
+		}
+		for _, pkg := range pkgs {
+			if strings.EqualFold(pkg, name) {
+				versions[pkg] = version
+			}
+		}
+	}
+	return versions
+}
+
+// parsePipInstalled reads the names of "pip list --format=freeze"
+func parsePipInstalled(output string) []string {
+	pkgs := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		if name, _, found := strings.Cut(strings.TrimSpace(line), "=="); found {
+			pkgs = append(pkgs, name)
+		}
+	}
+	return pkgs
+}
+
+// npmTreeRegex matches the packages of "npm ls" ("├── typescript@5.0.2")
+var npmTreeRegex = regexp.MustCompile(`^[^\w@]*(@?[^@\s]+)@(\S+)`)
+
+// parseNpmTree reads the output of "npm ls -g --depth=0"
+func parseNpmTree(output string, pkgs []string) map[string]string {
+	versions := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		match := npmTreeRegex.FindStringSubmatch(line)
+		if match != nil && ContainsString(pkgs, match[1]) {
+			versions[match[1]] = match[2]
+		}
+	}
+	return versions
+}
+
+// parseNpmInstalled reads the names of "npm ls -g --depth=0"
+func parseNpmInstalled(output string) []string {
+	pkgs := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		if match := npmTreeRegex.FindStringSubmatch(line); match != nil {
+			pkgs = append(pkgs, match[1])
+		}
+	}
+	return pkgs
+}
+
+// parseCargoList reads the output of "cargo install --list" ("ripgrep v13.0.0:", followed by the binaries)
+func parseCargoList(output string, pkgs []string) map[string]string {
+	versions := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || line[0] == ' ' || !ContainsString(pkgs, fields[0]) {
+			continue
+		}
+		versions[fields[0]] = strings.TrimSuffix(strings.TrimPrefix(fields[1], "v"), ":")
+	}
+	return versions
+}
+
+// parseCargoInstalled reads the names of "cargo install --list"
+func parseCargoInstalled(output string) []string {
+	pkgs := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && line[0] != ' ' {
+			pkgs = append(pkgs, fields[0])
+		}
+	}
+	return pkgs
+}
+
+// parseTableOutdated reads tables with a header where the name and the candidate version are on the given columns (pip and npm)
+func parseTableOutdated(name, candidate int) func(output string) map[string]string {
+	return func(output string) map[string]string {
+		candidates := map[string]string{}
+		for i, line := range strings.Split(strings.TrimSpace(output), "\n") {
+			fields := strings.Fields(line)
+			if i == 0 || len(fields) <= candidate || strings.HasPrefix(fields[0], "---") {
+				continue
+			}
+			candidates[fields[name]] = fields[candidate]
+		}
+		return candidates
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkgm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kpango/glg"
+)
+
+// LockFile is the file (on the project) that records the exact version of every installed package
+const LockFile = ".develbox/lock.json"
+
+// Lock maps every package to the version that was installed
+type Lock struct {
+	// Packages were installed as root (packages and devpackages on the config)
+	Packages map[string]string `json:"packages"`
+	// UserPackages were installed as the user (userpkgs on the config)
+	UserPackages map[string]string `json:"user_packages"`
+	// Managers has the packages of every package manager on image.pkgmanagers
+	Managers map[string]map[string]string `json:"managers,omitempty"`
+}
+
+// versions returns the versions changed by the operation
+func (l *Lock) versions(e *Operation) map[string]string {
+	if e.Manager != "" {
+		if l.Managers == nil {
+			l.Managers = map[string]map[string]string{}
+		}
+		if l.Managers[e.Manager] == nil {
+			l.Managers[e.Manager] = map[string]string{}
+		}
+		return l.Managers[e.Manager]
+	}
+	if e.UserOperation {
+		return l.UserPackages
+	}
+	return l.Packages
+}
+
+// ReadLock reads the lock file of the project. Returns an empty lock if it doesn't exist.
+func ReadLock() (Lock, error) {
+	lock := Lock{Packages: map[string]string{}, UserPackages: map[string]string{}}
+
+	data, err := os.ReadFile(LockFile)
+	if os.IsNotExist(err) {
+		return lock, nil
+	}
+	if err != nil {
+		return lock, err
+	}
+
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return lock, err
+	}
+	if lock.Packages == nil {
+		lock.Packages = map[string]string{}
+	}
+	if lock.UserPackages == nil {
+		lock.UserPackages = map[string]string{}
+	}
+	return lock, nil
+}
+
+// WriteLock replaces the lock file of the project
+func WriteLock(lock Lock) error {
+	data, err := json.MarshalIndent(lock, "", "\t")
+	if err != nil {
+		return err
+	}
+	return config.WriteFileAtomic(LockFile, append(data, '\n'), 0644)
+}
+
+// QueryVersions asks the package manager for the installed version of the packages. The result is keyed by the package name, packages that aren't installed are left out.
+func QueryVersions(cfg *config.Structure, pkgs []string, user bool) (map[string]string, error) {
+	return QueryManagerVersions(cfg, "", pkgs, user)
+}
+
+// QueryManagerVersions is QueryVersions for one of the package managers on image.pkgmanagers ("" for the system one)
+func QueryManagerVersions(cfg *config.Structure, manager string, pkgs []string, user bool) (map[string]string, error) {
+	versions := map[string]string{}
+	pkgs = PackageNames(pkgs)
+	if len(pkgs) == 0 {
+		return versions, nil
+	}
+	driver, err := managerDriver(cfg, manager)
+	if err != nil {
+		return versions, err
+	}
+	if !driver.Supports("query") {
+		return versions, fmt.Errorf("the package manager doesn't define a query operation")
+	}
+
+	opert := NewOperation("query", pkgs, []string{}, true)
+	opert.UserOperation = user
+	opert.Manager = manager
+
+	// Most package managers fail if one of the packages isn't installed,
+	// but they still print the others.
+	out, err := opert.Output(cfg)
+	versions = driver.Parse(string(out), pkgs)
+	if err != nil && len(versions) == 0 {
+		return versions, err
+	}
+	return versions, nil
+}
+
+// ParseVersions reads the "<name> <version>" lines printed by the query operation.
+//
+// It also understands the "<name>-<version>" lines printed by apk and nix, and the "<name>.<arch> <version>" lines printed by dnf.
+func ParseVersions(output string, pkgs []string) map[string]string {
+	versions := map[string]string{}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		name := fields[0]
+		if ContainsString(pkgs, name) && len(fields) > 1 {
+			versions[name] = fields[1]
+			continue
+		}
+
+		if i := strings.LastIndex(name, "."); i > 0 && ContainsString(pkgs, name[:i]) && len(fields) > 1 {
+			versions[name[:i]] = fields[1]
+			continue
+		}
+
+		for _, pkg := range pkgs {
+			version := strings.TrimPrefix(name, pkg+"-")
+			if version != name && version != "" && version[0] >= '0' && version[0] <= '9' {
+				versions[pkg] = version
+				break
+			}
+		}
+	}
+	return versions
+}
+
+// SupportsQuery returns true if the package manager of the config can print the installed versions
+func SupportsQuery(cfg *config.Structure) bool {
+	return supportsQuery(cfg, "")
+}
+
+// supportsQuery returns true if the package manager with that name can print the installed versions
+func supportsQuery(cfg *config.Structure, manager string) bool {
+	driver, err := managerDriver(cfg, manager)
+	return err == nil && driver.Supports("query")
+}
+
+// UpdateLock queries the version of every package on the config and replaces the lock file
+func UpdateLock(cfg *config.Structure) error {
+	lock := Lock{Packages: map[string]string{}, UserPackages: map[string]string{}}
+
+	var err error
+	pkgs := append(append([]string{}, cfg.Packages...), cfg.DevPackages...)
+	lock.Packages, err = QueryVersions(cfg, pkgs, false)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Podman.Rootless {
+		userPkgs := append(append([]string{}, cfg.UserPkgs.Packages...), cfg.UserPkgs.DevPackages...)
+		lock.UserPackages, err = QueryVersions(cfg, userPkgs, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range cfg.PkgManagerNames() {
+		if !supportsQuery(cfg, name) {
+			continue
+		}
+		pkgManager := cfg.Image.PkgManagers[name]
+		pkgs := append(append([]string{}, pkgManager.Packages...), pkgManager.DevPackages...)
+		versions, err := QueryManagerVersions(cfg, name, pkgs, pkgManager.User)
+		if err != nil {
+			return err
+		}
+		if lock.Managers == nil {
+			lock.Managers = map[string]map[string]string{}
+		}
+		lock.Managers[name] = versions
+	}
+	return WriteLock(lock)
+}
+
+// updateLock records the versions of the packages of a successful operation on the lock file
+func (e *Operation) updateLock(cfg *config.Structure) error {
+	if e.Type != "add" && e.Type != "del" {
+		return nil
+	}
+	if !supportsQuery(cfg, e.Manager) {
+		glg.Debug("The package manager doesn't define a query operation, not updating the lock file")
+		return nil
+	}
+
+	lock, err := ReadLock()
+	if err != nil {
+		return err
+	}
+
+	versions := lock.versions(e)
+	for _, pkg := range PackageNames(e.Packages) {
+		delete(versions, pkg)
+	}
+
+	if e.Type == "add" {
+		installed, err := QueryManagerVersions(cfg, e.Manager, e.Packages, e.UserOperation)
+		if err != nil {
+			return err
+		}
+		for pkg, version := range installed {
+			versions[pkg] = version
+		}
+	}
+	return WriteLock(lock)
+}
+
+// PinPackages sets the version of the packages that are on the lock (the "pin" modifier renders them later). The rest are returned as they are.
+func PinPackages(pkgs []string, versions map[string]string) (pinned []string, missing []string) {
+	pinned, missing = []string{}, []string{}
+	for _, pkg := range pkgs {
+		spec := ParseSpec(pkg)
+		version, ok := versions[spec.Name]
+		if !ok {
+			missing = append(missing, pkg)
+			pinned = append(pinned, pkg)
+			continue
+		}
+
+		spec.Version, spec.Operator = version, ""
+		pinned = append(pinned, spec.String())
+	}
+	return pinned, missing
+}
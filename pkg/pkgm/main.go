@@ -20,8 +20,6 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"regexp"
-	"strings"
 
 	"github.com/kadmuffin/develbox/pkg/config"
 	"github.com/kadmuffin/develbox/pkg/podman"
@@ -36,26 +34,60 @@ type Operation struct {
 	AutoInstall   bool     `json:"auto-install"`
 	DevInstall    bool     `json:"dev-install"`
 	UserOperation bool     `json:"run-as-user"`
+	// Manager is the name of the package manager on image.pkgmanagers, empty for the system one
+	Manager string `json:"manager,omitempty"`
 }
 
-// NewOperation creates a new operation struct that is used to request a transaction. Accepted types: ("add", "del", "update", "upgrade", "search", "clean").
+// NewOperation creates a new operation struct that is used to request a transaction. Accepted types: ("add", "del", "update", "upgrade", "search", "clean", "query").
 func NewOperation(opType string, packages []string, flags []string, autoInstall bool) Operation {
 	return Operation{Type: opType, Packages: packages, Flags: flags, AutoInstall: autoInstall, UserOperation: false}
 }
 
+// SetManager makes the operation use one of the package managers on image.pkgmanagers ("" or "system" for the system one).
+// The operation runs as the user if the package manager says so.
+func (e *Operation) SetManager(cfg *config.Structure, name string) error {
+	if name == config.SystemPkgManager {
+		name = ""
+	}
+	pkgManager, err := cfg.GetPkgManager(name)
+	if err != nil {
+		return err
+	}
+
+	e.Manager = name
+	if name != "" {
+		e.UserOperation = pkgManager.User
+	}
+	return nil
+}
+
 // UpdateConfig updates the config file with the new packages
 func (e *Operation) UpdateConfig(cfg *config.Structure) {
+	if e.Manager != "" {
+		pkgManager, ok := cfg.Image.PkgManagers[e.Manager]
+		if !ok {
+			return
+		}
+		e.updateLists(&pkgManager.Packages, &pkgManager.DevPackages)
+		cfg.Image.PkgManagers[e.Manager] = pkgManager
+		return
+	}
+
 	pkgsP := &cfg.Packages
 	devPkgsP := &cfg.DevPackages
 	if e.UserOperation {
 		pkgsP = &cfg.UserPkgs.Packages
 		devPkgsP = &cfg.UserPkgs.DevPackages
 	}
+	e.updateLists(pkgsP, devPkgsP)
+}
 
-	if pkgsP == nil {
+// updateLists adds or removes the packages of the operation from the lists
+func (e *Operation) updateLists(pkgsP *[]string, devPkgsP *[]string) {
+	if *pkgsP == nil {
 		*pkgsP = []string{}
 	}
-	if devPkgsP == nil {
+	if *devPkgsP == nil {
 		*devPkgsP = []string{}
 	}
 
@@ -77,8 +109,48 @@ func (e *Operation) UpdateConfig(cfg *config.Structure) {
 	}
 }
 
-// Process processes the transaction and updates the config reference. Returns an error in case of failure.
+// Apply locks the project, processes the transaction and writes the config, only if the transaction succeeded.
+func (e *Operation) Apply(cfg *config.Structure) error {
+	lock, err := config.LockProject()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if err := e.run(cfg); err != nil {
+		return err
+	}
+	return e.Commit(cfg)
+}
+
+// Commit saves a transaction that succeeded: the config file (read again, something else could have changed it
+// while the package manager ran), the installed packages and the lock file. The caller has to hold the project lock.
+func (e *Operation) Commit(cfg *config.Structure) error {
+	updated, err := config.Update(func(fresh *config.Structure) error {
+		e.UpdateConfig(fresh)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*cfg = updated
+
+	e.saveInstalled(cfg)
+	return nil
+}
+
+// Process processes the transaction and updates the config reference, only if the command succeeded. Returns an error in case of failure.
 func (e *Operation) Process(cfg *config.Structure) error {
+	if err := e.run(cfg); err != nil {
+		return err
+	}
+	e.UpdateConfig(cfg)
+	e.saveInstalled(cfg)
+	return nil
+}
+
+// run runs the command of the transaction, attached to the terminal
+func (e *Operation) run(cfg *config.Structure) error {
 	cmd, err := e.ProcessCmd(cfg, podman.Attach{
 		Stdin:     true,
 		Stdout:    true,
@@ -88,21 +160,36 @@ func (e *Operation) Process(cfg *config.Structure) error {
 	if err != nil {
 		return err
 	}
-
-	e.UpdateConfig(cfg)
-
 	return cmd.Run()
 }
 
+// saveInstalled updates the installed packages (see ReadState) and the lock file after the transaction
+func (e *Operation) saveInstalled(cfg *config.Structure) {
+	if err := e.updateState(cfg); err != nil {
+		glg.Warnf("Couldn't save the installed packages: %s", err)
+	}
+	if err := e.updateLock(cfg); err != nil {
+		glg.Warnf("Couldn't update %s: %s", LockFile, err)
+	}
+}
+
 // ProcessCmd processes the transaction and returns a command. Config updates have to be handle separately.
 func (e *Operation) ProcessCmd(cfg *config.Structure, attach podman.Attach) (*exec.Cmd, error) {
-	var pman podman.Podman
-	if !podman.InsideContainer() && os.Getuid() != 0 {
-		pman = podman.New(cfg.Podman.Path)
+	// The engine is only used from the host, inside the container the command runs directly (as root, or
+	// if it only reads). The rest of the operations have to be sent to the socket.
+	var pman podman.Engine
+	if !podman.InsideContainer() {
+		pman = podman.FromConfig(cfg.Podman)
+	} else if os.Getuid() != 0 && !ContainsString(readOnlyOperations, e.Type) {
+		return nil, fmt.Errorf("can't run the %s operation inside the container without root, it has to be sent to the socket", e.Type)
 	}
 	cname := cfg.Container.Name
-	baseCmd, err := e.StringCommand(&cfg.Image.PkgManager)
+	pkgManager, err := cfg.GetPkgManager(e.Manager)
+	if err != nil {
+		return nil, err
+	}
 
+	baseCmd, err := e.StringCommand(&pkgManager)
 	if err != nil {
 		return nil, err
 	}
@@ -118,65 +205,43 @@ func (e *Operation) ProcessCmd(cfg *config.Structure, attach podman.Attach) (*ex
 // StringCommand returns the string that will be send to
 // the container. For example: "apt install -y vim".
 func (e *Operation) StringCommand(cfg *config.PackageManager) (string, error) {
-	var baseCmd string
-
-	switch e.Type {
-	case "add":
-		baseCmd = cfg.Operations.Add
-	case "del":
-		baseCmd = cfg.Operations.Del
-	case "search":
-		baseCmd = cfg.Operations.Srch
-	case "update":
-		baseCmd = cfg.Operations.Upd
-	case "upgrade":
-		baseCmd = cfg.Operations.Upg
-	case "clean":
-		baseCmd = cfg.Operations.Clean
-
-	// Throw an error if the operation is not supported
-	default:
-		return "", glg.Errorf("couldn't find the key '%s' on the list of supported operations", e.Type)
-	}
-
-	// We want to process the flags and packages, but only if they are not empty
-	flags := strings.Join(e.Flags, " ")
-	if flags != "" {
-		flags += " "
+	driver, err := GetDriver(cfg)
+	if err != nil {
+		return "", err
 	}
-	packages := processPackages(e.Packages, cfg.Modifiers[e.Type])
+	return driver.Command(e)
+}
 
-	// This is where we replace the "{args}" string with the flags and packages
-	modifBase := strings.Replace(baseCmd, "{args}", fmt.Sprintf("%s%s", flags, packages), 1)
-	regex := regexp.MustCompile(`\{(.*)\}`)
+// readOnlyOperations are the operations that don't change the container
+var readOnlyOperations = []string{"search", "query", "installed", "outdated"}
 
-	// Anything that is inside {} will be replaced
-	// at this point.
-	// If auto install is true, we will remove the brackets
-	// else, we just replace them.
-	if e.AutoInstall {
-		modifBase = regex.ReplaceAllString(modifBase, "$1")
-	} else {
-		modifBase = regex.ReplaceAllString(modifBase, "")
+// Output runs the operation and returns what it printed (stderr is still shown to the user)
+func (e *Operation) Output(cfg *config.Structure) ([]byte, error) {
+	cmd, err := e.ProcessCmd(cfg, podman.Attach{Stderr: true})
+	if err != nil {
+		return nil, err
 	}
-
-	return modifBase, nil
+	cmd.Stdin = nil
+	cmd.Stdout = nil
+	return cmd.Output()
 }
 
 // sendCommand runs a podman command with the config's pkgmanager settings.
-func (e *Operation) sendCommand(cname, base string, pman podman.Podman, attach podman.Attach) *exec.Cmd {
+func (e *Operation) sendCommand(cname, base string, pman podman.Engine, attach podman.Attach) *exec.Cmd {
 
 	arguments := []string{cname, base}
 
-	if podman.InsideContainer() && os.Getuid() == 0 {
-		if e.UserOperation {
+	// Operations that only read don't need root
+	readOnly := ContainsString(readOnlyOperations, e.Type)
+
+	if podman.InsideContainer() && (os.Getuid() == 0 || readOnly) {
+		if e.UserOperation && !readOnly {
 			glg.Warn("Running as root inside a container, but the operation is set to run as user. Ignoring the flag.")
 		}
 
-		arguments = strings.Split(base, " ")
 		// Because we are inside the container, and we
-		// are root, we can just run the command.
-		cmd := exec.Command(arguments[0], arguments[1:]...)
+		// are root (or we only read), we can just run the command.
+		cmd := exec.Command("sh", "-c", base)
 		cmd.Stdin = os.Stdin
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
@@ -205,7 +270,7 @@ func Read(data []byte) (Operation, error) {
 
 // String returns a string representation of the operation
 func (e *Operation) String() string {
-	return fmt.Sprintf("Type: %s, Packages: %s, Flags: %s, AutoInstall: %t, DevInstall: %t, UserOperation: %t", e.Type, e.Packages, e.Flags, e.AutoInstall, e.DevInstall, e.UserOperation)
+	return fmt.Sprintf("Type: %s, Packages: %s, Flags: %s, AutoInstall: %t, DevInstall: %t, UserOperation: %t, Manager: %s", e.Type, e.Packages, e.Flags, e.AutoInstall, e.DevInstall, e.UserOperation, e.Manager)
 }
 
 // ToJSON converts the operation to a JSON string
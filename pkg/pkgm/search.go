@@ -0,0 +1,224 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkgm
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+)
+
+// ErrNoSearchParser is returned when the driver can't parse the results of the search operation (like the custom driver)
+var ErrNoSearchParser = errors.New("the package manager doesn't know how to parse the search results")
+
+// SearchResult is a package found by the search operation
+type SearchResult struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+	Installed   bool   `json:"installed"`
+}
+
+// Search runs the search operation and parses its output
+func Search(cfg *config.Structure, opert Operation) ([]SearchResult, error) {
+	driver, err := managerDriver(cfg, opert.Manager)
+	if err != nil {
+		return nil, err
+	}
+	if driver.ParseSearch == nil {
+		return nil, ErrNoSearchParser
+	}
+
+	// Some package managers fail when nothing matches, the rest of the errors (like a stopped container) are returned
+	opert.Type = "search"
+	out, err := opert.Output(cfg)
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case driver.SearchNoMatch != 0 && errors.As(err, &exitErr) && exitErr.ExitCode() == driver.SearchNoMatch:
+		return []SearchResult{}, nil
+	default:
+		return nil, fmt.Errorf("the search failed: %w", err)
+	}
+	results := driver.ParseSearch(string(out))
+
+	if !driver.SearchShowsInstalled && driver.Supports("query") && len(results) > 0 {
+		names := []string{}
+		for _, result := range results {
+			names = append(names, result.Name)
+		}
+
+		installed, err := QueryManagerVersions(cfg, opert.Manager, names, opert.UserOperation)
+		if err != nil {
+			return results, err
+		}
+		for i, result := range results {
+			version, ok := installed[result.Name]
+			results[i].Installed = ok
+			if ok && result.Version == "" {
+				results[i].Version = version
+			}
+		}
+	}
+	return results, nil
+}
+
+// splitNameVersion splits "<name>-<version>", the version starts with the first digit after a "-"
+func splitNameVersion(pkg string) (name string, version string) {
+	for i := 0; i < len(pkg)-1; i++ {
+		if pkg[i] == '-' && pkg[i+1] >= '0' && pkg[i+1] <= '9' {
+			return pkg[:i], pkg[i+1:]
+		}
+	}
+	return pkg, ""
+}
+
+// parseIndented reads outputs where every package has a header line, followed by the description on indented lines
+func parseIndented(output string, header func(line string) (SearchResult, bool)) []SearchResult {
+	results := []SearchResult{}
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(results) > 0 {
+				last := &results[len(results)-1]
+				// dnf starts them with ": "
+				more := strings.TrimPrefix(strings.TrimSpace(line), ": ")
+				last.Description = strings.TrimSpace(last.Description + " " + more)
+			}
+			continue
+		}
+
+		if result, ok := header(line); ok {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+var (
+	// "vim/stable 2:9.0.1378-2 amd64 [installed]"
+	aptSearchRegex = regexp.MustCompile(`^([^/\s]+)/\S+\s+(\S+)(.*)$`)
+	// "extra/vim 9.0.1000-1 (group) [installed]"
+	pacmanSearchRegex = regexp.MustCompile(`^\S+/(\S+)\s+(\S+)(.*)$`)
+	// "[*] vim-9.0.1000_1   Vim editor"
+	xbpsSearchRegex = regexp.MustCompile(`^\[(.)\]\s+(\S+)\s*(.*)$`)
+)
+
+// parseAptSearch reads the output of "apt search"
+func parseAptSearch(output string) []SearchResult {
+	return parseIndented(output, func(line string) (SearchResult, bool) {
+		match := aptSearchRegex.FindStringSubmatch(line)
+		if match == nil {
+			return SearchResult{}, false
+		}
+		return SearchResult{Name: match[1], Version: match[2], Installed: strings.Contains(match[3], "[installed")}, true
+	})
+}
+
+// parsePacmanSearch reads the output of "pacman -Ss"
+func parsePacmanSearch(output string) []SearchResult {
+	return parseIndented(output, func(line string) (SearchResult, bool) {
+		match := pacmanSearchRegex.FindStringSubmatch(line)
+		if match == nil {
+			return SearchResult{}, false
+		}
+		return SearchResult{Name: match[1], Version: match[2], Installed: strings.Contains(match[3], "[installed")}, true
+	})
+}
+
+// parseDnfSearch reads the output of "dnf search" ("vim-enhanced.x86_64 : A version of the VIM editor")
+func parseDnfSearch(output string) []SearchResult {
+	return parseIndented(output, func(line string) (SearchResult, bool) {
+		name, description, found := strings.Cut(line, " : ")
+		if !found || strings.HasPrefix(line, "=") {
+			return SearchResult{}, false
+		}
+		name = strings.TrimSpace(name)
+		if i := strings.LastIndex(name, "."); i > 0 {
+			name = name[:i]
+		}
+		return SearchResult{Name: name, Description: strings.TrimSpace(description)}, true
+	})
+}
+
+// parseApkSearch reads the output of "apk search -v" ("vim-9.0.1000-r0 - Improved vi-style text editor")
+func parseApkSearch(output string) []SearchResult {
+	results := []SearchResult{}
+	for _, line := range strings.Split(output, "\n") {
+		pkg, description, _ := strings.Cut(strings.TrimSpace(line), " - ")
+		if pkg == "" {
+			continue
+		}
+		name, version := splitNameVersion(pkg)
+		results = append(results, SearchResult{Name: name, Version: version, Description: description})
+	}
+	return results
+}
+
+// parseZypperSearch reads the table printed by "zypper search"
+func parseZypperSearch(output string) []SearchResult {
+	results := []SearchResult{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) < 3 {
+			continue
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if fields[1] == "Name" || fields[1] == "" {
+			continue
+		}
+		results = append(results, SearchResult{Name: fields[1], Description: fields[2], Installed: strings.HasPrefix(fields[0], "i")})
+	}
+	return results
+}
+
+// parseXbpsSearch reads the output of "xbps-query -Rs"
+func parseXbpsSearch(output string) []SearchResult {
+	results := []SearchResult{}
+	for _, line := range strings.Split(output, "\n") {
+		match := xbpsSearchRegex.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		name, version := splitNameVersion(match[2])
+		results = append(results, SearchResult{Name: name, Version: version, Description: match[3], Installed: match[1] == "*"})
+	}
+	return results
+}
+
+// parseNixSearch reads the output of "nix-env -qaP --description" ("nixpkgs.vim  vim-9.0.1000  The most popular clone of the VI editor")
+func parseNixSearch(output string) []SearchResult {
+	results := []SearchResult{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		// The attribute is what we install (nixpkgs.{package})
+		name := strings.TrimPrefix(fields[0], "nixpkgs.")
+		_, version := splitNameVersion(fields[1])
+		results = append(results, SearchResult{Name: name, Version: version, Description: strings.Join(fields[2:], " ")})
+	}
+	return results
+}
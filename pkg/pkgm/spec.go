@@ -0,0 +1,96 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkgm
+
+import "strings"
+
+// Spec is a package as written on the config or on the command line: "[repo/]name[@version]".
+//
+// "name=version" is also understood, so packages written for apt or apk keep working, and so are the
+// version constraints of pip ("requests==2.31.0", "numpy>=1.20").
+// Names that start with "@" are npm scopes ("@types/node@18"), they don't have a repo.
+type Spec struct {
+	Name string `json:"name"`
+	// Version is the version constraint, the package manager decides what it means (for example, "18" or "18.*")
+	Version string `json:"version,omitempty"`
+	// Operator compares the version (">=", "~=", ...), it's empty for an exact version. Only some package managers understand them.
+	Operator string `json:"operator,omitempty"`
+	// Repo is the repository to install the package from
+	Repo string `json:"repo,omitempty"`
+}
+
+// versionOperators are the comparisons of pip's version constraints, longer ones first. "==" and "===" are exact versions.
+var versionOperators = []string{"===", "==", ">=", "<=", "~=", "!=", ">", "<"}
+
+// ParseSpec reads a package spec, like "nodejs@18" or "edge/nodejs"
+func ParseSpec(pkg string) Spec {
+	spec := Spec{}
+	if i := strings.Index(pkg, "/"); i > 0 && pkg[0] != '@' {
+		spec.Repo, pkg = pkg[:i], pkg[i+1:]
+	}
+	if i, operator := findOperator(pkg); i > 0 {
+		spec.Name, spec.Version = pkg[:i], pkg[i+len(operator):]
+		if operator != "==" && operator != "===" {
+			spec.Operator = operator
+		}
+		return spec
+	}
+	if i := strings.LastIndexAny(pkg, "@="); i > 0 {
+		spec.Version, pkg = pkg[i+1:], pkg[:i]
+	}
+	spec.Name = pkg
+	return spec
+}
+
+// findOperator returns the position of the first version operator on pkg (-1 if there isn't one)
+func findOperator(pkg string) (int, string) {
+	for i := range pkg {
+		for _, operator := range versionOperators {
+			if strings.HasPrefix(pkg[i:], operator) {
+				return i, operator
+			}
+		}
+	}
+	return -1, ""
+}
+
+// String returns the spec as it's written on the config
+func (s Spec) String() string {
+	pkg := s.Name
+	if s.Repo != "" {
+		pkg = s.Repo + "/" + pkg
+	}
+	switch {
+	case s.Operator != "":
+		pkg += s.Operator + s.Version
+	case s.Version != "":
+		pkg += "@" + s.Version
+	}
+	return pkg
+}
+
+// PackageNames returns the name of every package, without versions or repositories
+func PackageNames(pkgs []string) []string {
+	names := []string{}
+	for _, pkg := range pkgs {
+		names = append(names, ParseSpec(pkg).Name)
+	}
+	return names
+}
+
+// ContainsPackage returns true if the list has a package with the same name (the version and repo are ignored)
+func ContainsPackage(list []string, pkg string) bool {
+	return ContainsString(PackageNames(list), ParseSpec(pkg).Name)
+}
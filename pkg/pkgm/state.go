@@ -0,0 +1,209 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkgm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/podman"
+	"github.com/kpango/glg"
+)
+
+// StateFile is the file (inside the container) that records the packages installed by develbox
+const StateFile = "/var/lib/develbox/packages.json"
+
+// State is the set of packages that develbox installed on the container
+type State struct {
+	// Packages were installed as root (packages and devpackages on the config)
+	Packages []string `json:"packages"`
+	// UserPackages were installed as the user (userpkgs on the config)
+	UserPackages []string `json:"user_packages"`
+	// Managers has the packages installed by every package manager on image.pkgmanagers
+	Managers map[string][]string `json:"managers,omitempty"`
+}
+
+// ReadState reads the state file of the container. Returns false if the container doesn't have one (created by an older version).
+func ReadState(cfg *config.Structure) (State, bool) {
+	var data []byte
+	var err error
+
+	if podman.InsideContainer() {
+		data, err = os.ReadFile(StateFile)
+	} else {
+		pman := podman.FromConfig(cfg.Podman)
+		cmd := pman.Exec([]string{cfg.Container.Name, fmt.Sprintf("cat %s 2>/dev/null", StateFile)}, map[string]string{}, true, true, podman.Attach{Stderr: true})
+		data, err = cmd.Output()
+	}
+
+	state := State{Packages: []string{}, UserPackages: []string{}}
+	if err != nil || len(bytes.TrimSpace(data)) == 0 {
+		return state, false
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		glg.Warnf("Ignoring the package state of the container, it's not valid: %s", err)
+		return state, false
+	}
+	return state, true
+}
+
+// WriteState replaces the state file of the container
+func WriteState(cfg *config.Structure, state State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	if podman.InsideContainer() {
+		if err := os.MkdirAll(filepath.Dir(StateFile), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(StateFile, data, 0644)
+	}
+
+	pman := podman.FromConfig(cfg.Podman)
+	command := fmt.Sprintf("mkdir -p %s && cat > %s", filepath.Dir(StateFile), StateFile)
+	cmd := pman.Exec([]string{cfg.Container.Name, command}, map[string]string{}, true, true, podman.Attach{Stderr: true})
+	cmd.Stdin = bytes.NewReader(data)
+	return cmd.Run()
+}
+
+// updateState adds or removes the packages of a successful operation from the state file
+func (e *Operation) updateState(cfg *config.Structure) error {
+	if e.Type != "add" && e.Type != "del" {
+		return nil
+	}
+
+	state, found := ReadState(cfg)
+	if !found {
+		// We don't know what else is installed, so we leave it to Reconcile
+		return nil
+	}
+
+	pkgs := &state.Packages
+	if e.UserOperation {
+		pkgs = &state.UserPackages
+	}
+	managerPkgs := state.Managers[e.Manager]
+	if e.Manager != "" {
+		pkgs = &managerPkgs
+	}
+
+	*pkgs = RemoveDuplicates(&e.Packages, pkgs)
+	if e.Type == "add" {
+		*pkgs = append(*pkgs, e.Packages...)
+	}
+
+	if e.Manager != "" {
+		if state.Managers == nil {
+			state.Managers = map[string][]string{}
+		}
+		state.Managers[e.Manager] = managerPkgs
+	}
+	return WriteState(cfg, state)
+}
+
+// Diff returns the packages that are wanted but not installed (add) and the ones that are installed but not wanted anymore (del).
+//
+// A package whose version or repo changed is installed again, but it's only removed if its name isn't wanted anymore.
+func Diff(installed []string, wanted []string) (add []string, del []string) {
+	add, del = []string{}, []string{}
+	for _, pkg := range wanted {
+		if !ContainsString(installed, pkg) && !ContainsString(add, pkg) {
+			add = append(add, pkg)
+		}
+	}
+	for _, pkg := range installed {
+		if !ContainsPackage(wanted, pkg) {
+			del = append(del, pkg)
+		}
+	}
+	return add, del
+}
+
+// Reconcile installs the packages on the config that the container doesn't have and removes the ones that were deleted from it.
+//
+// If the container doesn't have a state file, every package is installed and nothing is removed.
+func Reconcile(cfg *config.Structure) error {
+	state, found := ReadState(cfg)
+
+	wanted := append(append([]string{}, cfg.Packages...), cfg.DevPackages...)
+	err := reconcileList(cfg, state.Packages, wanted, found, false)
+	if err != nil {
+		return err
+	}
+	state.Packages = wanted
+
+	if cfg.Podman.Rootless {
+		wantedUser := append(append([]string{}, cfg.UserPkgs.Packages...), cfg.UserPkgs.DevPackages...)
+		err := reconcileList(cfg, state.UserPackages, wantedUser, found, true)
+		if err != nil {
+			return err
+		}
+		state.UserPackages = wantedUser
+	}
+
+	for _, name := range cfg.PkgManagerNames() {
+		pkgManager := cfg.Image.PkgManagers[name]
+		wanted := append(append([]string{}, pkgManager.Packages...), pkgManager.DevPackages...)
+		installed, known := state.Managers[name]
+		err := reconcileManager(cfg, name, installed, wanted, found && known, pkgManager.User)
+		if err != nil {
+			return err
+		}
+		if state.Managers == nil {
+			state.Managers = map[string][]string{}
+		}
+		state.Managers[name] = wanted
+	}
+
+	return WriteState(cfg, state)
+}
+
+// reconcileList runs the del and add operations needed to go from installed to wanted
+func reconcileList(cfg *config.Structure, installed []string, wanted []string, known bool, user bool) error {
+	return reconcileManager(cfg, "", installed, wanted, known, user)
+}
+
+// reconcileManager is reconcileList for one of the package managers on image.pkgmanagers ("" for the system one)
+func reconcileManager(cfg *config.Structure, manager string, installed []string, wanted []string, known bool, user bool) error {
+	add, del := Diff(installed, wanted)
+	if !known {
+		add, del = wanted, []string{}
+	}
+
+	for _, opert := range []Operation{NewOperation("del", del, []string{}, true), NewOperation("add", add, []string{}, true)} {
+		if len(opert.Packages) == 0 {
+			continue
+		}
+
+		glg.Infof("Reconciling packages, running %s for: %v", opert.Type, opert.Packages)
+		opert.UserOperation = user
+		opert.Manager = manager
+		cmd, err := opert.ProcessCmd(cfg, podman.Attach{Stdin: true, Stdout: true, Stderr: true})
+		if err != nil {
+			return err
+		}
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("couldn't %s %v: %s", opert.Type, opert.Packages, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkgm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kpango/glg"
+)
+
+// PackageStatus compares a package of the config with what is installed in the container
+type PackageStatus struct {
+	Name string `json:"name"`
+	// Manager is the name of the package manager on image.pkgmanagers, empty for the system one
+	Manager string `json:"manager,omitempty"`
+	// Section is where the package is declared on the config ("packages", "devpackages", "userpkgs.packages"
+	// or "userpkgs.devpackages"), it's empty for packages that are installed but not declared.
+	Section   string `json:"section"`
+	Installed bool   `json:"installed"`
+	Version   string `json:"version"`
+	// Candidate is the version an upgrade would install, empty if the package is up to date
+	Candidate string `json:"candidate"`
+}
+
+// statusSection is a list of packages on the config
+type statusSection struct {
+	name string
+	pkgs []string
+	user bool
+}
+
+// Status queries the container for the installed and candidate versions of the packages on the config.
+// Packages that were installed explicitly but aren't on the config are added after the ones of each package manager.
+func Status(cfg *config.Structure) ([]PackageStatus, error) {
+	sections := []statusSection{
+		{"packages", cfg.Packages, false},
+		{"devpackages", cfg.DevPackages, false},
+	}
+	if cfg.Podman.Rootless {
+		sections = append(sections,
+			statusSection{"userpkgs.packages", cfg.UserPkgs.Packages, true},
+			statusSection{"userpkgs.devpackages", cfg.UserPkgs.DevPackages, true})
+	}
+
+	statuses, err := managerStatus(cfg, "", false, sections)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range cfg.PkgManagerNames() {
+		pkgManager := cfg.Image.PkgManagers[name]
+		managerStatuses, err := managerStatus(cfg, name, pkgManager.User, []statusSection{
+			{"packages", pkgManager.Packages, pkgManager.User},
+			{"devpackages", pkgManager.DevPackages, pkgManager.User},
+		})
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, managerStatuses...)
+	}
+	return statuses, nil
+}
+
+// managerStatus returns the status of the packages of one package manager ("" for the system one), user tells how to look for undeclared and outdated packages
+func managerStatus(cfg *config.Structure, manager string, user bool, sections []statusSection) ([]PackageStatus, error) {
+	driver, err := managerDriver(cfg, manager)
+	if err != nil {
+		return nil, err
+	}
+	if !driver.Supports("query") {
+		return nil, fmt.Errorf("the package manager doesn't define a query operation")
+	}
+
+	statuses := []PackageStatus{}
+	declared := []string{}
+	for _, section := range sections {
+		versions, err := QueryManagerVersions(cfg, manager, section.pkgs, section.user)
+		if err != nil {
+			return nil, err
+		}
+		for _, pkg := range PackageNames(section.pkgs) {
+			version, ok := versions[pkg]
+			statuses = append(statuses, PackageStatus{Name: pkg, Manager: manager, Section: section.name, Installed: ok, Version: version})
+			if !strings.HasPrefix(section.name, "userpkgs.") {
+				declared = append(declared, pkg)
+			}
+		}
+	}
+
+	if driver.Supports("installed") {
+		undeclared, err := undeclaredPackages(cfg, driver, manager, declared, user)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, undeclared...)
+	}
+
+	if driver.Supports("outdated") {
+		opert := NewOperation("outdated", []string{}, []string{}, true)
+		opert.Manager = manager
+		opert.UserOperation = user
+		// Some package managers exit with an error when there are updates (dnf returns 100)
+		out, err := opert.Output(cfg)
+		if err != nil {
+			glg.Debugf("The outdated operation failed: %s", err)
+		}
+		candidates := driver.ParseOutdatedOutput(string(out))
+		for i, status := range statuses {
+			if candidate, ok := candidates[status.Name]; ok && status.Installed && candidate != status.Version {
+				statuses[i].Candidate = candidate
+			}
+		}
+	}
+	return statuses, nil
+}
+
+// undeclaredPackages returns the packages that were installed explicitly but aren't on the config
+func undeclaredPackages(cfg *config.Structure, driver Driver, manager string, declared []string, user bool) ([]PackageStatus, error) {
+	opert := NewOperation("installed", []string{}, []string{}, true)
+	opert.Manager = manager
+	opert.UserOperation = user
+	out, err := opert.Output(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs := []string{}
+	for _, pkg := range driver.ParseInstalledOutput(string(out)) {
+		if !ContainsString(declared, pkg) && !ContainsString(pkgs, pkg) {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	sort.Strings(pkgs)
+
+	versions, err := QueryManagerVersions(cfg, manager, pkgs, user)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []PackageStatus{}
+	for _, pkg := range pkgs {
+		statuses = append(statuses, PackageStatus{Name: pkg, Manager: manager, Installed: true, Version: versions[pkg]})
+	}
+	return statuses, nil
+}
+
+// ParseInstalledOutput reads the output of the installed operation
+func (d Driver) ParseInstalledOutput(output string) []string {
+	if d.ParseInstalled != nil {
+		return d.ParseInstalled(output)
+	}
+	pkgs := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			pkgs = append(pkgs, fields[0])
+		}
+	}
+	return pkgs
+}
+
+// ParseOutdatedOutput reads the output of the outdated operation, it maps every package to its candidate version
+func (d Driver) ParseOutdatedOutput(output string) map[string]string {
+	if d.ParseOutdated != nil {
+		return d.ParseOutdated(output)
+	}
+	candidates := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		if fields := strings.Fields(line); len(fields) > 1 {
+			candidates[fields[0]] = fields[1]
+		}
+	}
+	return candidates
+}
+
+// parseApkWorld reads /etc/apk/world, removing the version constraints ("nodejs>=18" -> "nodejs")
+func parseApkWorld(output string) []string {
+	pkgs := []string{}
+	for _, line := range strings.Fields(output) {
+		if i := strings.IndexAny(line, "=<>~@"); i >= 0 {
+			line = line[:i]
+		}
+		if line != "" {
+			pkgs = append(pkgs, line)
+		}
+	}
+	return pkgs
+}
+
+// parseZypperInstalled reads the table printed by "zypper packages", the name is on the third column
+func parseZypperInstalled(output string) []string {
+	pkgs := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) < 4 {
+			continue
+		}
+		name := strings.TrimSpace(fields[2])
+		if name == "" || name == "Name" {
+			continue
+		}
+		pkgs = append(pkgs, name)
+	}
+	return pkgs
+}
+
+// parseNameVersions reads lines that start with "<name>-<version>" (xbps and nix)
+func parseNameVersions(output string) []string {
+	pkgs := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		name, _ := splitNameVersion(fields[0])
+		pkgs = append(pkgs, name)
+	}
+	return pkgs
+}
+
+// parseAptOutdated reads the output of "apt list --upgradable" ("vim/stable 2:9.0.1378-2 amd64 [upgradable from: ...]")
+func parseAptOutdated(output string) map[string]string {
+	candidates := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		if match := aptSearchRegex.FindStringSubmatch(line); match != nil {
+			candidates[match[1]] = match[2]
+		}
+	}
+	return candidates
+}
+
+// parseDnfOutdated reads the output of "dnf check-update" ("vim-enhanced.x86_64  2:9.0.1000-1.fc37  updates")
+func parseDnfOutdated(output string) map[string]string {
+	candidates := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		i := strings.LastIndex(fields[0], ".")
+		if i <= 0 {
+			continue
+		}
+		candidates[fields[0][:i]] = fields[1]
+	}
+	return candidates
+}
+
+// parseApkOutdated reads the output of "apk version -l '<'" ("nodejs-18.12.1-r0  <  18.14.2-r0"), nix uses the same format
+func parseApkOutdated(output string) map[string]string {
+	candidates := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != "<" {
+			continue
+		}
+		name, _ := splitNameVersion(fields[0])
+		candidates[name] = fields[2]
+	}
+	return candidates
+}
+
+// parseNixOutdated reads the output of "nix-env -qc" ("vim-9.0.1000  <  9.0.1200")
+func parseNixOutdated(output string) map[string]string {
+	return parseApkOutdated(output)
+}
+
+// parsePacmanOutdated reads the output of "pacman -Qu" ("vim 9.0.1000-1 -> 9.0.1200-1")
+func parsePacmanOutdated(output string) map[string]string {
+	candidates := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[2] != "->" {
+			continue
+		}
+		candidates[fields[0]] = fields[3]
+	}
+	return candidates
+}
+
+// parseZypperOutdated reads the table printed by "zypper list-updates" (S | Repository | Name | Current Version | Available Version | Arch)
+func parseZypperOutdated(output string) map[string]string {
+	candidates := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) < 5 {
+			continue
+		}
+		name := strings.TrimSpace(fields[2])
+		if name == "" || name == "Name" {
+			continue
+		}
+		candidates[name] = strings.TrimSpace(fields[4])
+	}
+	return candidates
+}
+
+// parseXbpsOutdated reads the output of "xbps-install -Sun" ("vim-9.0.1200_1 update x86_64 ...")
+func parseXbpsOutdated(output string) map[string]string {
+	candidates := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[1] != "update" {
+			continue
+		}
+		name, version := splitNameVersion(fields[0])
+		candidates[name] = version
+	}
+	return candidates
+}
@@ -0,0 +1,495 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package template is the small template language used by the package manager operations and modifiers.
+//
+//	{name}        the value of a variable, shell quoted (lists are joined with spaces, every item is quoted)
+//	{name|raw}    the value of a variable, without quoting
+//	{?name:text}  text, only if the variable is set (true, or not empty)
+//	{!name:text}  text, only if the variable isn't set
+//	{name:text}   text for every item of a list, the item is on {item} (and on {package} for packages)
+//	{-y}          kept from the old syntax, the same as {?confirm:-y}
+//	{{ and }}     a literal brace
+//
+// "${...}" and "%{...}" are copied as they are, they are used by the query formats of dpkg-query and rpm.
+package template
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Kind is the type of a variable
+type Kind int
+
+const (
+	// Text is a single word
+	Text Kind = iota
+	// List is a list of words
+	List
+	// Bool can only be used by conditionals
+	Bool
+)
+
+// String returns the name of the kind
+func (k Kind) String() string {
+	switch k {
+	case List:
+		return "list"
+	case Bool:
+		return "bool"
+	default:
+		return "text"
+	}
+}
+
+// Schema lists the variables (and their kind) that a template can use
+type Schema map[string]Kind
+
+// OperationSchema are the variables of the operations
+var OperationSchema = Schema{
+	"packages": List,
+	"flags":    List,
+	"args":     List,
+	"confirm":  Bool,
+	"user":     Bool,
+	"arch":     Text,
+}
+
+// ModifierSchema are the variables of the modifiers, which run once per package
+var ModifierSchema = Schema{
+	"package": Text,
+	"version": Text,
+	"repo":    Text,
+	"confirm": Bool,
+	"user":    Bool,
+	"arch":    Text,
+}
+
+// Value is the value of a variable
+type Value struct {
+	Kind  Kind
+	Words []string
+	Set   bool
+	// Safe values are already quoted
+	Safe bool
+}
+
+// Word creates a text value
+func Word(word string) Value {
+	return Value{Kind: Text, Words: []string{word}}
+}
+
+// Words creates a list value
+func Words(words []string) Value {
+	return Value{Kind: List, Words: words}
+}
+
+// SafeWords creates a list value that is already quoted
+func SafeWords(words []string) Value {
+	return Value{Kind: List, Words: words, Safe: true}
+}
+
+// Flag creates a bool value
+func Flag(set bool) Value {
+	return Value{Kind: Bool, Set: set}
+}
+
+// isSet returns true if the value is true or not empty
+func (v Value) isSet() bool {
+	if v.Kind == Bool {
+		return v.Set
+	}
+	return strings.Join(v.Words, "") != ""
+}
+
+// Vars are the values used to execute a template
+type Vars map[string]Value
+
+type nodeType int
+
+const (
+	textNode nodeType = iota
+	varNode
+	condNode
+	eachNode
+)
+
+type node struct {
+	typ    nodeType
+	text   string
+	name   string
+	raw    bool
+	negate bool
+	body   []node
+}
+
+// Template is a parsed template
+type Template struct {
+	source string
+	nodes  []node
+}
+
+// Parse parses a template
+func Parse(source string) (*Template, error) {
+	p := parser{source: source}
+	nodes, err := p.parse(false)
+	if err != nil {
+		return nil, fmt.Errorf("template '%s': %s", source, err)
+	}
+	return &Template{source: source, nodes: nodes}, nil
+}
+
+// Validate parses a template and checks that it only uses the variables of the schema
+func Validate(source string, schema Schema) error {
+	t, err := Parse(source)
+	if err != nil {
+		return err
+	}
+	if err := check(t.nodes, schema); err != nil {
+		return fmt.Errorf("template '%s': %s", source, err)
+	}
+	return nil
+}
+
+// Execute renders the template, quoting the values
+func (t *Template) Execute(vars Vars) (string, error) {
+	return t.execute(vars, true)
+}
+
+// ExecuteRaw renders the template without quoting the values
+func (t *Template) ExecuteRaw(vars Vars) (string, error) {
+	return t.execute(vars, false)
+}
+
+func (t *Template) execute(vars Vars, quote bool) (string, error) {
+	var out strings.Builder
+	if err := render(&out, t.nodes, vars, quote); err != nil {
+		return "", fmt.Errorf("template '%s': %s", t.source, err)
+	}
+	return out.String(), nil
+}
+
+// safeRegex matches the words that don't need quotes
+var safeRegex = regexp.MustCompile(`^[a-zA-Z0-9@%+=:,./_-]+$`)
+
+// Quote quotes a word so the shell reads it as it is
+func Quote(word string) string {
+	if safeRegex.MatchString(word) {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// render writes the nodes to out
+func render(out *strings.Builder, nodes []node, vars Vars, quote bool) error {
+	for _, n := range nodes {
+		switch n.typ {
+		case textNode:
+			out.WriteString(n.text)
+
+		case varNode:
+			value, ok := vars[n.name]
+			if !ok {
+				return fmt.Errorf("unknown variable '%s'", n.name)
+			}
+			if value.Kind == Bool {
+				return fmt.Errorf("'%s' can only be used on conditionals", n.name)
+			}
+			words := []string{}
+			for _, word := range value.Words {
+				if quote && !n.raw && !value.Safe {
+					word = Quote(word)
+				}
+				words = append(words, word)
+			}
+			out.WriteString(strings.Join(words, " "))
+
+		case condNode:
+			value, ok := vars[n.name]
+			if !ok {
+				return fmt.Errorf("unknown variable '%s'", n.name)
+			}
+			if value.isSet() != n.negate {
+				if err := render(out, n.body, vars, quote); err != nil {
+					return err
+				}
+			}
+
+		case eachNode:
+			value, ok := vars[n.name]
+			if !ok {
+				return fmt.Errorf("unknown variable '%s'", n.name)
+			}
+			if value.Kind != List {
+				return fmt.Errorf("'%s' isn't a list", n.name)
+			}
+			items := []string{}
+			for _, word := range value.Words {
+				item := Value{Kind: Text, Words: []string{word}, Safe: value.Safe}
+				itemVars := Vars{}
+				for name, v := range vars {
+					itemVars[name] = v
+				}
+				itemVars["item"] = item
+				itemVars[singular(n.name)] = item
+
+				var itemOut strings.Builder
+				if err := render(&itemOut, n.body, itemVars, quote); err != nil {
+					return err
+				}
+				items = append(items, itemOut.String())
+			}
+			out.WriteString(strings.Join(items, " "))
+		}
+	}
+	return nil
+}
+
+// Uses returns true if the template uses the variable (also inside conditionals and lists)
+func (t *Template) Uses(name string) bool {
+	return uses(t.nodes, name)
+}
+
+// uses returns true if a node uses the variable
+func uses(nodes []node, name string) bool {
+	for _, n := range nodes {
+		if n.typ != textNode && n.name == name {
+			return true
+		}
+		if uses(n.body, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns an error if the nodes use a variable that isn't on the schema
+func check(nodes []node, schema Schema) error {
+	for _, n := range nodes {
+		if n.typ == textNode {
+			continue
+		}
+
+		kind, ok := schema[n.name]
+		if !ok {
+			return fmt.Errorf("unknown variable '%s'", n.name)
+		}
+
+		switch n.typ {
+		case varNode:
+			if kind == Bool {
+				return fmt.Errorf("'%s' can only be used on conditionals", n.name)
+			}
+		case condNode:
+			if err := check(n.body, schema); err != nil {
+				return err
+			}
+		case eachNode:
+			if kind != List {
+				return fmt.Errorf("'%s' is a %s, only lists can be repeated", n.name, kind)
+			}
+			itemSchema := Schema{}
+			for name, k := range schema {
+				itemSchema[name] = k
+			}
+			itemSchema["item"] = Text
+			itemSchema[singular(n.name)] = Text
+			if err := check(n.body, itemSchema); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// singular returns the name of the items of a list ("packages" -> "package")
+func singular(name string) string {
+	return strings.TrimSuffix(name, "s")
+}
+
+// parser reads a template one byte at a time
+type parser struct {
+	source string
+	pos    int
+}
+
+// parse reads nodes until the end of the source, or until the "}" that closes the current expression (when nested)
+func (p *parser) parse(nested bool) ([]node, error) {
+	nodes := []node{}
+	var text strings.Builder
+
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, node{typ: textNode, text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for p.pos < len(p.source) {
+		c := p.source[p.pos]
+
+		switch {
+		case c == '{' && p.peek(1) == '{':
+			text.WriteByte('{')
+			p.pos += 2
+
+		case c == '}' && p.peek(1) == '}' && !nested:
+			text.WriteByte('}')
+			p.pos += 2
+
+		case c == '}':
+			if !nested {
+				return nil, fmt.Errorf("unexpected '}' at %d", p.pos)
+			}
+			flush()
+			p.pos++
+			return nodes, nil
+
+		case c == '{' && p.pos > 0 && (p.source[p.pos-1] == '$' || p.source[p.pos-1] == '%'):
+			literal, err := p.literal()
+			if err != nil {
+				return nil, err
+			}
+			text.WriteString(literal)
+
+		case c == '{':
+			flush()
+			n, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, n)
+
+		default:
+			text.WriteByte(c)
+			p.pos++
+		}
+	}
+
+	if nested {
+		return nil, fmt.Errorf("missing '}'")
+	}
+	flush()
+	return nodes, nil
+}
+
+// peek returns the byte at pos+offset, or 0 if it's out of the source
+func (p *parser) peek(offset int) byte {
+	if p.pos+offset >= len(p.source) {
+		return 0
+	}
+	return p.source[p.pos+offset]
+}
+
+// literal reads "{...}" as it is
+func (p *parser) literal() (string, error) {
+	end := strings.IndexByte(p.source[p.pos:], '}')
+	if end < 0 {
+		return "", fmt.Errorf("missing '}' for the '{' at %d", p.pos)
+	}
+	literal := p.source[p.pos : p.pos+end+1]
+	p.pos += end + 1
+	return literal, nil
+}
+
+// expression reads the expression that starts at pos ("{")
+func (p *parser) expression() (node, error) {
+	start := p.pos
+	p.pos++
+
+	switch p.peek(0) {
+	case '?', '!':
+		negate := p.peek(0) == '!'
+		p.pos++
+		name, err := p.ident()
+		if err != nil {
+			return node{}, err
+		}
+		if p.peek(0) != ':' {
+			return node{}, fmt.Errorf("expected ':' after '%s' at %d", name, p.pos)
+		}
+		p.pos++
+		body, err := p.parse(true)
+		if err != nil {
+			return node{}, err
+		}
+		return node{typ: condNode, name: name, negate: negate, body: body}, nil
+
+	case '-':
+		// The old syntax, anything inside {} was only added when auto installing
+		end := strings.IndexByte(p.source[p.pos:], '}')
+		if end < 0 {
+			return node{}, fmt.Errorf("missing '}' for the '{' at %d", start)
+		}
+		text := p.source[p.pos : p.pos+end]
+		p.pos += end + 1
+		return node{typ: condNode, name: "confirm", body: []node{{typ: textNode, text: text}}}, nil
+	}
+
+	name, err := p.ident()
+	if err != nil {
+		return node{}, err
+	}
+
+	switch p.peek(0) {
+	case '}':
+		p.pos++
+		return node{typ: varNode, name: name}, nil
+
+	case '|':
+		p.pos++
+		filter, err := p.ident()
+		if err != nil {
+			return node{}, err
+		}
+		if filter != "raw" {
+			return node{}, fmt.Errorf("unknown filter '%s', only 'raw' is supported", filter)
+		}
+		if p.peek(0) != '}' {
+			return node{}, fmt.Errorf("expected '}' at %d", p.pos)
+		}
+		p.pos++
+		return node{typ: varNode, name: name, raw: true}, nil
+
+	case ':':
+		p.pos++
+		body, err := p.parse(true)
+		if err != nil {
+			return node{}, err
+		}
+		return node{typ: eachNode, name: name, body: body}, nil
+	}
+
+	return node{}, fmt.Errorf("expected '}', '|' or ':' after '%s' at %d", name, p.pos)
+}
+
+// ident reads a variable name
+func (p *parser) ident() (string, error) {
+	start := p.pos
+	for p.pos < len(p.source) {
+		c := p.source[p.pos]
+		if c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || p.pos > start && c >= '0' && c <= '9' {
+			p.pos++
+			continue
+		}
+		break
+	}
+
+	if p.pos == start {
+		return "", fmt.Errorf("expected a variable name at %d", start)
+	}
+	return p.source[start:p.pos], nil
+}
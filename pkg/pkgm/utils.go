@@ -39,27 +39,13 @@ func ContainsString(list []string, match string) bool {
 	return false
 }
 
-// RemoveDuplicates returns a new array based on `baseList` without anything repeated on the append list.
+// RemoveDuplicates returns a new array based on `baseList` without the packages on the append list. Packages are compared by name, so "nodejs@18" replaces "nodejs".
 func RemoveDuplicates(appendList *[]string, baseList *[]string) []string {
 	newList := []string{}
 	for _, item := range *baseList {
-		if !ContainsString(*appendList, item) {
+		if !ContainsPackage(*appendList, item) {
 			newList = append(newList, item)
 		}
 	}
 	return newList
 }
-
-// processPackages combines the packages with the argModifier (for example, by adding the prefix or suffix) and returns a string
-func processPackages(packages []string, argModifier string) string {
-	pkgs := []string{}
-
-	if !strings.Contains(argModifier, "{package}") {
-		return strings.Join(packages, " ")
-	}
-
-	for _, pkg := range packages {
-		pkgs = append(pkgs, strings.ReplaceAll(argModifier, "{package}", pkg))
-	}
-	return strings.Join(pkgs, " ")
-}
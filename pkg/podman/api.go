@@ -0,0 +1,156 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podman
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kpango/glg"
+)
+
+// Streamer is implemented by the engines that can run commands without forking the engine executable.
+type Streamer interface {
+	// ExecStream runs a command inside a running container, streams its I/O and returns the exit code.
+	ExecStream(name string, opts ExecOptions, stdin io.Reader, stdout, stderr io.Writer) (int, error)
+}
+
+// API is an engine that talks to the REST API for the operations it supports.
+// Everything else (create, exec with a TTY, commit, build...) falls back to the CLI engine.
+type API struct {
+	Engine
+	client *APIClient
+}
+
+// NewAPI creates an engine that uses the socket at path and falls back to the CLI engine.
+func NewAPI(fallback Engine, socket string) *API {
+	glg.Infof("Using the REST API through '%s'.", socket)
+	return &API{Engine: fallback, client: NewAPIClient(socket)}
+}
+
+// Client returns the REST API client
+func (e *API) Client() *APIClient {
+	return e.client
+}
+
+// Inspect returns the information of a container. Returns ErrNotFound if it doesn't exist.
+func (e *API) Inspect(name string) (ContainerInfo, error) {
+	return e.client.Inspect(name)
+}
+
+// List returns the containers (including their size) that have every label ("key" or "key=value").
+func (e *API) List(all bool, labels []string) ([]ContainerInfo, error) {
+	summaries, err := e.client.List(all, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := []ContainerInfo{}
+	for _, summary := range summaries {
+		info, err := e.client.Inspect(summary.ID)
+		if err == ErrNotFound {
+			// Removed while we were listing
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		info.SizeRw, info.SizeRootFs = summary.SizeRw, summary.SizeRootFs
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Exists returns a boolean that indicates if the container was found.
+func (e *API) Exists(name string) bool {
+	_, err := e.client.Inspect(name)
+	if err != nil && err != ErrNotFound {
+		glg.Warnf("Failed to check if container exists: %s", err)
+	}
+	return err == nil
+}
+
+// IsRunning checks if the container is running
+func (e *API) IsRunning(name string) bool {
+	info, err := e.client.Inspect(name)
+	return err == nil && info.State.Running
+}
+
+// Start starts a container. Any extra flag is handled by the CLI.
+func (e *API) Start(args []string, attach Attach) error {
+	if len(args) != 1 {
+		return e.Engine.Start(args, attach)
+	}
+	return e.client.Start(args[0])
+}
+
+// Stop stops a container. Any extra flag is handled by the CLI.
+func (e *API) Stop(args []string, attach Attach) error {
+	if len(args) != 1 {
+		return e.Engine.Stop(args, attach)
+	}
+	return e.client.Stop(args[0])
+}
+
+// Remove removes a container even if it's running. Any extra flag is handled by the CLI.
+func (e *API) Remove(args []string, attach Attach) error {
+	if len(args) != 1 {
+		return e.Engine.Remove(args, attach)
+	}
+	return e.client.Remove(args[0], true)
+}
+
+// Version gets the engine version using the API.
+func (e *API) Version() (major, minor, patch int64, err error) {
+	version, err := e.client.Version()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return parseVersion(version)
+}
+
+// ExecStream runs a command inside a running container, streams its I/O and returns the exit code.
+func (e *API) ExecStream(name string, opts ExecOptions, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	glg.Infof("Executing command using the API: %s", strings.Join(opts.Cmd, " "))
+	return e.client.Exec(name, opts, stdin, stdout, stderr)
+}
+
+// DefaultSocket returns the usual location of the API socket for an engine kind. Empty if the engine doesn't have one.
+func DefaultSocket(kind string) string {
+	switch kind {
+	case KindPodman:
+		if os.Getuid() == 0 {
+			return "/run/podman/podman.sock"
+		}
+
+		runtimeDir, found := os.LookupEnv("XDG_RUNTIME_DIR")
+		if !found {
+			runtimeDir = fmt.Sprintf("/run/user/%d", os.Getuid())
+		}
+		return filepath.Join(runtimeDir, "podman", "podman.sock")
+	case KindDocker:
+		host := os.Getenv("DOCKER_HOST")
+		if strings.HasPrefix(host, "unix://") {
+			return strings.TrimPrefix(host, "unix://")
+		}
+		return "/var/run/docker.sock"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,330 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podman
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kpango/glg"
+)
+
+// cli contains the logic shared by all the engines that follow the docker CLI.
+type cli struct {
+	path string
+}
+
+// newCLI verifies that the executable exists and returns a new cli struct.
+func newCLI(path string) cli {
+	glg.Infof("Container engine path set to '%s'.", path)
+	cmd := exec.Command(path, "--version")
+	glg.Infof("Verifying that the container engine exists using '%s'.", cmd.String())
+
+	if err := cmd.Run(); err != nil {
+		glg.Fatalf("Can't access the container engine executable: %s", err)
+	}
+
+	return cli{path: path}
+}
+
+// Path returns the path to the engine executable.
+func (e *cli) Path() string {
+	return e.path
+}
+
+// cmd is private function that manages the command creation. Created as a boilerplate for other public functions.
+func (e *cli) cmd(args []string, attach Attach) *exec.Cmd {
+	cmd := exec.Command(e.path, args...)
+
+	if attach.Stdin {
+		cmd.Stdin = os.Stdin
+	}
+	if attach.Stdout {
+		cmd.Stdout = os.Stdout
+	}
+	if attach.Stderr {
+		cmd.Stderr = os.Stderr
+	}
+
+	return cmd
+}
+
+// Create creates a container using the arguments provided.
+func (e *cli) Create(args []string, attach Attach) *exec.Cmd {
+	params := []string{"run", "-t", "--init"}
+	params = append(params, args...)
+
+	return PrintCommandR("Creating container using the following command: %s", e.cmd(params, attach))
+
+}
+
+// Exec executes a command inside a running container and attaches (Stdin, Stdout) if "attach" is true.
+func (e *cli) Exec(args []string, envVars map[string]string, sh bool, root bool, attach Attach) *exec.Cmd {
+	uid := os.Getuid()
+	params := []string{"exec", "-i"}
+
+	if attach.PseudoTTY {
+		params = append(params, "-t")
+	}
+
+	if attach == *new(Attach) {
+		params = append(params, "-d")
+	}
+
+	for k, v := range envVars {
+		params = append(params, "-e", fmt.Sprintf("%s=%s", k, ReplaceEnvVars(v)))
+	}
+
+	if root {
+		params = append(params, "--user", "0:0")
+	} else {
+		params = append(params, "--user", fmt.Sprintf("%d:%d", uid, uid))
+	}
+
+	params = append(params, args[0])
+
+	if sh {
+		params = append(params, "sh", "-c")
+	}
+
+	params = append(params, args[1:]...)
+
+	return PrintCommandR("Executing command: %s", e.cmd(params, attach))
+}
+
+// Start starts a container and returns an error in case of failure. The first argument has to be the container's name/id.
+func (e *cli) Start(args []string, attach Attach) error {
+	params := []string{"start"}
+	params = append(params, args...)
+
+	return PrintCommandR("Starting container using the following arguments:\n  - %s", e.cmd(params, attach)).Run()
+}
+
+// Stop stops a container and returns an error in case of failure. In arguments, the first argument has to be the container's name/id if no flag are added before of the name.
+func (e *cli) Stop(args []string, attach Attach) error {
+	params := []string{"stop"}
+	params = append(params, args...)
+
+	return PrintCommandR("Stopping container using the following arguments:\n  - %s", e.cmd(params, attach)).Run()
+}
+
+// Remove removes a container and returns an error in case of failure. In arguments, the first argument has to be the container's name/id if no flag are added before of the name.
+func (e *cli) Remove(args []string, attach Attach) error {
+	e.Stop(args, attach)
+
+	params := []string{"rm"}
+	params = append(params, args...)
+
+	return PrintCommandR("Removing container using the following arguments:\n  - %s", e.cmd(params, attach)).Run()
+}
+
+// Inspect returns the information of a container. Returns ErrNotFound if it doesn't exist.
+func (e *cli) Inspect(name string) (ContainerInfo, error) {
+	infos, err := e.inspect([]string{name})
+	if err != nil {
+		return ContainerInfo{}, err
+	}
+
+	if len(infos) == 0 {
+		return ContainerInfo{}, ErrNotFound
+	}
+	return infos[0], nil
+}
+
+// List returns the containers (including their size) that have every label ("key" or "key=value").
+func (e *cli) List(all bool, labels []string) ([]ContainerInfo, error) {
+	params := []string{"ps", "-q", "--no-trunc"}
+	if all {
+		params = append(params, "-a")
+	}
+	for _, label := range labels {
+		params = append(params, "--filter", "label="+label)
+	}
+
+	out, err := e.cmd(params, Attach{}).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %s", err)
+	}
+
+	ids := strings.Fields(string(out))
+	if len(ids) == 0 {
+		return []ContainerInfo{}, nil
+	}
+
+	return e.inspect(append([]string{"--size"}, ids...))
+}
+
+// ListImages returns the images that have every label ("key" or "key=value").
+func (e *cli) ListImages(labels []string) ([]ImageInfo, error) {
+	params := []string{"images", "-q", "--no-trunc"}
+	for _, label := range labels {
+		params = append(params, "--filter", "label="+label)
+	}
+
+	out, err := e.cmd(params, Attach{}).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %s", err)
+	}
+
+	// The same image is printed once per tag
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, id := range strings.Fields(string(out)) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	images := []ImageInfo{}
+	if len(ids) == 0 {
+		return images, nil
+	}
+
+	out, err = e.cmd(append([]string{"image", "inspect"}, ids...), Attach{}).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect images: %s", err)
+	}
+
+	if err := json.Unmarshal(out, &images); err != nil {
+		return nil, fmt.Errorf("failed to parse image inspect output: %s", err)
+	}
+	return images, nil
+}
+
+// inspect runs "container inspect" with the arguments and parses the JSON output.
+func (e *cli) inspect(args []string) ([]ContainerInfo, error) {
+	params := append([]string{"container", "inspect"}, args...)
+
+	var stderr bytes.Buffer
+	cmd := e.cmd(params, Attach{})
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(strings.ToLower(stderr.String()), "no such") {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to inspect container: %s %s", err, stderr.String())
+	}
+
+	infos := []ContainerInfo{}
+	if err := json.Unmarshal(out, &infos); err != nil {
+		return nil, fmt.Errorf("failed to parse inspect output: %s", err)
+	}
+
+	for i := range infos {
+		infos[i].Name = strings.TrimPrefix(infos[i].Name, "/")
+	}
+	return infos, nil
+}
+
+// Exists returns a boolean that indicates if the container was found.
+func (e *cli) Exists(name string) bool {
+	_, err := e.Inspect(name)
+	if err != nil && err != ErrNotFound {
+		glg.Warnf("Failed to check if container exists: %s", err)
+	}
+	return err == nil
+}
+
+// Copy copies files into the container
+func (e *cli) Copy(args []string, attach Attach) *exec.Cmd {
+	e.Stop(args, attach)
+
+	params := []string{"cp"}
+	params = append(params, args...)
+
+	return PrintCommandR("Running copy using the following arguments:\n  - %s", e.cmd(params, attach))
+}
+
+// Version gets the current engine version
+func (e *cli) Version() (major, minor, patch int64, err error) {
+	data, err := e.cmd([]string{"--version"}, Attach{}).Output()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return parseVersion(string(data))
+}
+
+// Build builds a new image from the context on path
+func (e *cli) Build(path string, tag string, attach Attach) *exec.Cmd {
+	params := []string{"build", "-t", tag, path}
+
+	return PrintCommandR("Running build using the following arguments:\n  - %s", e.cmd(params, attach))
+}
+
+// Attach attaches to the container
+func (e *cli) Attach(args []string, attach Attach) *exec.Cmd {
+	params := []string{"attach"}
+	params = append(params, args...)
+
+	return PrintCommandR("Running attach using the following arguments:\n  - %s", e.cmd(params, attach))
+}
+
+// RawCommand runs any engine subcommand (for example: ps)
+func (e *cli) RawCommand(args []string, attach Attach) *exec.Cmd {
+	return e.cmd(args, attach)
+}
+
+// IsRunning checks if the container is running
+func (e *cli) IsRunning(name string) bool {
+	info, err := e.Inspect(name)
+	return err == nil && info.State.Running
+}
+
+// Commit commits the container to an image
+func (e *cli) Commit(args []string, attach Attach) *exec.Cmd {
+	params := []string{"commit"}
+	params = append(params, args...)
+
+	return PrintCommandR("Running commit using the following arguments:\n  - %s", e.cmd(params, attach))
+}
+
+var versionRegex = regexp.MustCompile(`([0-9]+)\.([0-9]+)\.([0-9]+)([0-9a-zA-z-\.]+)*`)
+
+// parseVersion finds the first semantic version on a string (for example, the output of "podman --version")
+func parseVersion(data string) (major, minor, patch int64, err error) {
+	parsed := versionRegex.FindStringSubmatch(data)
+
+	if parsed == nil {
+		return 0, 0, 0, errors.New("unable to parse version")
+	}
+
+	major, err = strconv.ParseInt(parsed[1], 10, 0)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	minor, err = strconv.ParseInt(parsed[2], 10, 0)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	patch, err = strconv.ParseInt(parsed[3], 10, 0)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return major, minor, patch, nil
+}
@@ -0,0 +1,353 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podman
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// APIVersion is the version of the docker compatible API used by the client. Podman serves it too.
+const APIVersion = "v1.41"
+
+// ErrNotFound is returned by the API client when the container doesn't exist.
+var ErrNotFound = errors.New("no such container")
+
+// APIClient talks to the REST API of the engine through its unix socket.
+type APIClient struct {
+	socket string
+	http   *http.Client
+}
+
+// ExecOptions contains the settings for running a command with the API.
+type ExecOptions struct {
+	// Cmd is the command and its arguments
+	Cmd []string `json:"Cmd"`
+	// Env is a list of "KEY=value" variables
+	Env []string `json:"Env"`
+	// User is the "uid:gid" that runs the command
+	User string `json:"User"`
+	// Tty allocates a pseudo-TTY (output won't be multiplexed)
+	Tty bool `json:"Tty"`
+}
+
+// NewExecOptions creates the options for a command the same way Engine.Exec() creates the arguments.
+func NewExecOptions(command []string, envVars map[string]string, sh bool, root bool) ExecOptions {
+	opts := ExecOptions{Cmd: command, Env: []string{}}
+
+	if sh {
+		opts.Cmd = append([]string{"sh", "-c"}, command...)
+	}
+
+	for k, v := range envVars {
+		opts.Env = append(opts.Env, fmt.Sprintf("%s=%s", k, ReplaceEnvVars(v)))
+	}
+
+	if root {
+		opts.User = "0:0"
+	} else {
+		opts.User = fmt.Sprintf("%d:%d", os.Getuid(), os.Getuid())
+	}
+
+	return opts
+}
+
+// NewAPIClient creates a client that connects to the unix socket at path.
+func NewAPIClient(socket string) *APIClient {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}
+
+	return &APIClient{socket: socket, http: &http.Client{Transport: transport}}
+}
+
+// Socket returns the path to the socket used by the client.
+func (c *APIClient) Socket() string {
+	return c.socket
+}
+
+// url returns the full URL for an API path. The host is ignored as we dial the socket directly.
+func (c *APIClient) url(path string, query url.Values) string {
+	u := fmt.Sprintf("http://develbox/%s%s", APIVersion, path)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// request creates a new request with a JSON body (if not nil).
+func (c *APIClient) request(method, path string, query url.Values, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.url(path, query), reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// do sends a request and decodes the JSON response into out (if not nil).
+func (c *APIClient) do(method, path string, query url.Values, body interface{}, out interface{}) error {
+	req, err := c.request(method, path, query, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// APIError is an error response (4xx or 5xx) of the engine
+type APIError struct {
+	// StatusCode is the HTTP status of the response
+	StatusCode int
+	// Message is the message sent by the engine (the HTTP status if there isn't one)
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return "engine API: " + e.Message
+}
+
+// checkResponse returns an APIError for 4xx and 5xx status codes using the message sent by the engine.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode < 400 {
+		return nil
+	}
+
+	var apiErr struct {
+		Message string `json:"message"`
+	}
+	json.NewDecoder(resp.Body).Decode(&apiErr)
+	if apiErr.Message == "" {
+		apiErr.Message = resp.Status
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: apiErr.Message}
+}
+
+// containerError returns ErrNotFound for the 404 of the routes of a container, the rest of the errors are returned as they are.
+// Only used by those routes, on the others a 404 means a wrong socket or API path.
+func containerError(err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Ping checks that the engine is answering on the socket.
+func (c *APIClient) Ping() error {
+	return c.do(http.MethodGet, "/_ping", nil, nil, nil)
+}
+
+// Version returns the engine version (for example: "4.3.0").
+func (c *APIClient) Version() (string, error) {
+	var version struct {
+		Version string `json:"Version"`
+	}
+	err := c.do(http.MethodGet, "/version", nil, nil, &version)
+	return version.Version, err
+}
+
+// Inspect returns the information of a container. Returns ErrNotFound if it doesn't exist.
+func (c *APIClient) Inspect(name string) (ContainerInfo, error) {
+	var info ContainerInfo
+	err := c.do(http.MethodGet, fmt.Sprintf("/containers/%s/json", url.PathEscape(name)), nil, nil, &info)
+	info.Name = strings.TrimPrefix(info.Name, "/")
+	return info, containerError(err)
+}
+
+// List returns the containers that have every label (in "key=value" or "key" format). If all is false, only running containers are returned.
+func (c *APIClient) List(all bool, labels []string) ([]ContainerSummary, error) {
+	query := url.Values{}
+	query.Set("all", fmt.Sprint(all))
+	query.Set("size", "true")
+
+	if len(labels) > 0 {
+		filters, err := json.Marshal(map[string][]string{"label": labels})
+		if err != nil {
+			return nil, err
+		}
+		query.Set("filters", string(filters))
+	}
+
+	containers := []ContainerSummary{}
+	err := c.do(http.MethodGet, "/containers/json", query, nil, &containers)
+	return containers, err
+}
+
+// Start starts a container. Returns ErrNotFound if it doesn't exist.
+func (c *APIClient) Start(name string) error {
+	return containerError(c.do(http.MethodPost, fmt.Sprintf("/containers/%s/start", url.PathEscape(name)), nil, nil, nil))
+}
+
+// Stop stops a container.
+func (c *APIClient) Stop(name string) error {
+	return c.do(http.MethodPost, fmt.Sprintf("/containers/%s/stop", url.PathEscape(name)), nil, nil, nil)
+}
+
+// Remove deletes a container, if force is true it's removed even if running. Returns ErrNotFound if it doesn't exist.
+func (c *APIClient) Remove(name string, force bool) error {
+	query := url.Values{}
+	query.Set("force", fmt.Sprint(force))
+	return containerError(c.do(http.MethodDelete, fmt.Sprintf("/containers/%s", url.PathEscape(name)), query, nil, nil))
+}
+
+// Exec runs a command inside a running container, streams its I/O and returns the exit code.
+func (c *APIClient) Exec(name string, opts ExecOptions, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	create := struct {
+		ExecOptions
+		AttachStdin  bool `json:"AttachStdin"`
+		AttachStdout bool `json:"AttachStdout"`
+		AttachStderr bool `json:"AttachStderr"`
+	}{opts, stdin != nil, true, true}
+
+	var created struct {
+		ID string `json:"Id"`
+	}
+	err := c.do(http.MethodPost, fmt.Sprintf("/containers/%s/exec", url.PathEscape(name)), nil, create, &created)
+	if err != nil {
+		return -1, err
+	}
+
+	if err := c.startExec(created.ID, opts.Tty, stdin, stdout, stderr); err != nil {
+		return -1, err
+	}
+
+	var result struct {
+		ExitCode int `json:"ExitCode"`
+	}
+	err = c.do(http.MethodGet, fmt.Sprintf("/exec/%s/json", created.ID), nil, nil, &result)
+	return result.ExitCode, err
+}
+
+// startExec starts an exec instance hijacking the connection so stdin and stdout can be streamed.
+func (c *APIClient) startExec(id string, tty bool, stdin io.Reader, stdout, stderr io.Writer) error {
+	req, err := c.request(http.MethodPost, fmt.Sprintf("/exec/%s/start", id), nil, map[string]bool{"Detach": false, "Tty": tty})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+
+	conn, err := net.Dial("unix", c.socket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return err
+	}
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+
+	if stdin != nil {
+		go func() {
+			io.Copy(conn, stdin)
+			if unixConn, ok := conn.(*net.UnixConn); ok {
+				unixConn.CloseWrite()
+			}
+		}()
+	}
+
+	if tty {
+		_, err = io.Copy(stdout, reader)
+		return err
+	}
+	return demux(reader, stdout, stderr)
+}
+
+// demux splits the multiplexed stream that the engine sends when no TTY is allocated.
+//
+// Each frame has an 8 byte header: [stream, 0, 0, 0, size (4 bytes, big endian)].
+func demux(reader io.Reader, stdout, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		_, err := io.ReadFull(reader, header)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var dst io.Writer
+		switch header[0] {
+		case 1:
+			dst = stdout
+		case 2:
+			dst = stderr
+		default:
+			dst = io.Discard
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(dst, reader, size); err != nil {
+			return err
+		}
+	}
+}
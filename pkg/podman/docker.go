@@ -0,0 +1,30 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podman
+
+// Docker is the engine that runs containers using the docker executable.
+type Docker struct {
+	cli
+}
+
+// NewDocker creates a new Docker engine with the path to the docker executable.
+func NewDocker(path string) Engine {
+	return &Docker{cli: newCLI(path)}
+}
+
+// Kind returns the engine kind
+func (e *Docker) Kind() string {
+	return KindDocker
+}
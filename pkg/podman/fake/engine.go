@@ -0,0 +1,676 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Version is the version that the fake engine reports on "--version".
+const Version = "podman version 4.3.0"
+
+// container is a container "created" by the fake engine.
+type container struct {
+	ID      string            `json:"id"`
+	Name    string            `json:"name"`
+	Image   string            `json:"image"`
+	Running bool              `json:"running"`
+	Labels  map[string]string `json:"labels"`
+	Created time.Time         `json:"created"`
+	Started time.Time         `json:"started"`
+	Stopped time.Time         `json:"stopped"`
+	Mounts  []mount           `json:"mounts"`
+	Files   map[string]string `json:"files"`
+}
+
+// mount is a bind mount of a fake container.
+type mount struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+// image is an image committed on the fake engine.
+type image struct {
+	ID      string            `json:"id"`
+	Tags    []string          `json:"tags"`
+	Labels  map[string]string `json:"labels"`
+	Created time.Time         `json:"created"`
+}
+
+// valueFlags are the flags of "run" that take the next argument as their value.
+var valueFlags = map[string]bool{
+	"--name": true, "--label": true, "-l": true, "-e": true, "--env": true,
+	"--mount": true, "-v": true, "--volume": true, "-p": true, "--publish": true,
+	"-w": true, "--workdir": true, "-u": true, "--user": true, "--userns": true,
+	"--passwd-entry": true, "--net": true, "--network": true, "--entrypoint": true,
+}
+
+// Main makes the current binary behave as the fake engine when DEVELBOX_FAKE_ENGINE is set. It never returns in that case.
+func Main() {
+	dir, ok := os.LookupEnv(EnvVar)
+	if !ok {
+		return
+	}
+
+	os.Exit(run(dir, os.Args[1:]))
+}
+
+// run records the call and returns the exit code for it.
+func run(dir string, args []string) int {
+	if err := record(dir, args); err != nil {
+		fmt.Fprintf(os.Stderr, "fake engine: %s\n", err)
+		return 125
+	}
+
+	responses, err := readResponses(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fake engine: %s\n", err)
+		return 125
+	}
+
+	sub := Subcommand(args)
+	if response, ok := responses[sub]; ok {
+		fmt.Print(response.Output)
+		return response.ExitCode
+	}
+
+	containers, err := readContainers(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fake engine: %s\n", err)
+		return 125
+	}
+
+	images, err := readImages(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fake engine: %s\n", err)
+		return 125
+	}
+
+	// Like the real CLIs, a command is required
+	if len(args) == 0 || (args[0] == "container" && len(args) == 1) {
+		fmt.Fprintln(os.Stderr, "Error: missing command 'COMMAND'")
+		return 125
+	}
+
+	params := args[1:]
+	if args[0] == "container" {
+		params = args[2:]
+	}
+
+	code := 0
+	switch sub {
+	case "--version":
+		fmt.Println(Version)
+	case "run":
+		code = runContainer(containers, params)
+	case "start", "stop", "rm":
+		code = setState(containers, sub, params)
+	case "exists":
+		if _, ok := lookup(containers, lastArg(params)); !ok {
+			code = 1
+		}
+	case "inspect":
+		code = inspect(containers, params)
+	case "ps":
+		code = list(containers, params)
+	case "exec":
+		code = execute(containers, params)
+	case "rename":
+		code = rename(containers, params)
+	case "commit":
+		code = commit(containers, images, params)
+	case "images":
+		code = listImages(images, params)
+	case "rmi":
+		code = removeImages(images, params)
+	case "image":
+		switch firstArg(params) {
+		case "inspect":
+			code = inspectImages(images, params[1:])
+		case "rm":
+			code = removeImages(images, params[1:])
+		}
+	}
+
+	if err := writeJSON(filepath.Join(dir, stateFile), containers); err != nil {
+		fmt.Fprintf(os.Stderr, "fake engine: %s\n", err)
+		return 125
+	}
+	if err := writeJSON(filepath.Join(dir, imagesFile), images); err != nil {
+		fmt.Fprintf(os.Stderr, "fake engine: %s\n", err)
+		return 125
+	}
+	return code
+}
+
+// record appends the call to the log.
+func record(dir string, args []string) error {
+	data, err := json.Marshal(Call{Args: args})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, logFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// readContainers reads the containers saved on dir.
+func readContainers(dir string) (map[string]*container, error) {
+	containers := map[string]*container{}
+	data, err := os.ReadFile(filepath.Join(dir, stateFile))
+	if os.IsNotExist(err) {
+		return containers, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return containers, json.Unmarshal(data, &containers)
+}
+
+// readImages reads the images saved on dir.
+func readImages(dir string) (map[string]*image, error) {
+	images := map[string]*image{}
+	data, err := os.ReadFile(filepath.Join(dir, imagesFile))
+	if os.IsNotExist(err) {
+		return images, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return images, json.Unmarshal(data, &images)
+}
+
+// runContainer parses the arguments of "run" and saves the new container.
+func runContainer(containers map[string]*container, params []string) int {
+	cont := &container{Running: true, Labels: map[string]string{}, Created: time.Now()}
+	cont.Started = cont.Created
+
+	for i := 0; i < len(params); i++ {
+		param := params[i]
+		if !strings.HasPrefix(param, "-") {
+			cont.Image = param
+			break
+		}
+
+		flag, value, hasValue := strings.Cut(param, "=")
+		if !hasValue && valueFlags[flag] && i+1 < len(params) {
+			i++
+			value = params[i]
+		}
+
+		switch flag {
+		case "--name":
+			cont.Name = value
+		case "--label", "-l":
+			key, val, _ := strings.Cut(value, "=")
+			cont.Labels[key] = val
+		case "-v", "--volume":
+			parts := strings.Split(value, ":")
+			if len(parts) > 1 {
+				cont.Mounts = append(cont.Mounts, mount{Source: parts[0], Destination: parts[1]})
+			}
+		case "--mount":
+			cont.Mounts = append(cont.Mounts, parseMount(value))
+		}
+	}
+
+	if _, ok := containers[cont.Name]; ok {
+		fmt.Fprintf(os.Stderr, "Error: the container name \"%s\" is already in use\n", cont.Name)
+		return 125
+	}
+
+	hash := sha256.Sum256([]byte(cont.Name))
+	cont.ID = hex.EncodeToString(hash[:])
+	containers[cont.Name] = cont
+
+	fmt.Println(cont.ID)
+	return 0
+}
+
+// parseMount parses the value of "--mount" (for example: type=bind,src=/a,dst=/b).
+func parseMount(value string) mount {
+	m := mount{}
+	for _, option := range strings.Split(value, ",") {
+		key, val, _ := strings.Cut(option, "=")
+		switch key {
+		case "source", "src":
+			m.Source = val
+		case "destination", "dst", "target":
+			m.Destination = val
+		}
+	}
+	return m
+}
+
+// setState starts, stops or removes the containers passed as arguments.
+func setState(containers map[string]*container, sub string, params []string) int {
+	for _, name := range params {
+		if strings.HasPrefix(name, "-") {
+			continue
+		}
+
+		cont, ok := lookup(containers, name)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: no container with name or ID \"%s\" found\n", name)
+			return 125
+		}
+
+		switch sub {
+		case "start":
+			cont.Running, cont.Started = true, time.Now()
+		case "stop":
+			if cont.Running {
+				cont.Running, cont.Stopped = false, time.Now()
+			}
+		case "rm":
+			delete(containers, cont.Name)
+		}
+	}
+	return 0
+}
+
+// inspect prints the containers using the "-f" template or as a JSON array.
+func inspect(containers map[string]*container, params []string) int {
+	format := ""
+	data := []map[string]interface{}{}
+
+	for i := 0; i < len(params); i++ {
+		if params[i] == "-f" || params[i] == "--format" {
+			i++
+			format = params[i]
+			continue
+		}
+		if strings.HasPrefix(params[i], "-") {
+			continue
+		}
+
+		cont, ok := lookup(containers, params[i])
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: no such container %s\n", params[i])
+			return 125
+		}
+		data = append(data, cont.inspectData())
+	}
+
+	if format == "" || format == "json" {
+		out, _ := json.MarshalIndent(data, "", "    ")
+		fmt.Println(string(out))
+		return 0
+	}
+
+	return printTemplate(format, data)
+}
+
+// list prints the containers like "ps" does.
+func list(containers map[string]*container, params []string) int {
+	all, quiet, format := false, false, "{{.ID}}\t{{.Names}}"
+	filters := []string{}
+	for i := 0; i < len(params); i++ {
+		switch params[i] {
+		case "-a", "--all":
+			all = true
+		case "-q", "--quiet":
+			quiet = true
+		case "--format":
+			i++
+			format = params[i]
+		case "--filter", "-f":
+			i++
+			filters = append(filters, params[i])
+		}
+	}
+
+	if quiet {
+		format = "{{.ID}}"
+	}
+
+	data := []map[string]interface{}{}
+	for _, cont := range containers {
+		if !all && !cont.Running || !cont.matches(filters) {
+			continue
+		}
+		data = append(data, map[string]interface{}{
+			"ID":     cont.ID[:12],
+			"Names":  cont.Name,
+			"Image":  cont.Image,
+			"Labels": cont.Labels,
+		})
+	}
+
+	return printTemplate(format, data)
+}
+
+// execute emulates the commands that develbox uses to read and write files ("cat <file>" and "cat > <file>"), others do nothing.
+func execute(containers map[string]*container, params []string) int {
+	i := 0
+	for ; i < len(params) && strings.HasPrefix(params[i], "-"); i++ {
+		switch params[i] {
+		case "-e", "--env", "-u", "--user", "-w", "--workdir":
+			i++
+		}
+	}
+
+	if i >= len(params) {
+		fmt.Fprintln(os.Stderr, "Error: exec requires a container")
+		return 125
+	}
+
+	cont, ok := lookup(containers, params[i])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: no container with name or ID \"%s\" found\n", params[i])
+		return 125
+	}
+
+	command := strings.Join(params[i+1:], " ")
+	command = strings.TrimPrefix(command, "sh -c ")
+
+	if match := writeRegex.FindStringSubmatch(command); match != nil {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return 1
+		}
+		if cont.Files == nil {
+			cont.Files = map[string]string{}
+		}
+		cont.Files[match[1]] = string(data)
+		return 0
+	}
+
+	if match := readRegex.FindStringSubmatch(command); match != nil {
+		data, ok := cont.Files[match[1]]
+		if !ok {
+			return 1
+		}
+		fmt.Print(data)
+	}
+	return 0
+}
+
+// readRegex and writeRegex match the commands emulated by execute
+var (
+	readRegex  = regexp.MustCompile(`^cat (\S+)( 2>/dev/null)?$`)
+	writeRegex = regexp.MustCompile(`cat > (\S+)$`)
+)
+
+// rename changes the name of a container.
+func rename(containers map[string]*container, params []string) int {
+	if len(params) != 2 {
+		fmt.Fprintln(os.Stderr, "Error: rename requires the container and the new name")
+		return 125
+	}
+
+	cont, ok := lookup(containers, params[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: no container with name or ID \"%s\" found\n", params[0])
+		return 125
+	}
+	if _, ok := containers[params[1]]; ok {
+		fmt.Fprintf(os.Stderr, "Error: the container name \"%s\" is already in use\n", params[1])
+		return 125
+	}
+
+	delete(containers, cont.Name)
+	cont.Name = params[1]
+	containers[cont.Name] = cont
+	return 0
+}
+
+// commit creates an image from a container, the image keeps the labels of the container.
+func commit(containers map[string]*container, images map[string]*image, params []string) int {
+	args := []string{}
+	for _, param := range params {
+		if !strings.HasPrefix(param, "-") {
+			args = append(args, param)
+		}
+	}
+
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: commit requires a container")
+		return 125
+	}
+
+	cont, ok := lookup(containers, args[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: no container with name or ID \"%s\" found\n", args[0])
+		return 125
+	}
+
+	img := &image{Labels: map[string]string{}, Created: time.Now(), Tags: args[1:]}
+	for key, value := range cont.Labels {
+		img.Labels[key] = value
+	}
+
+	hash := sha256.Sum256([]byte(cont.ID + img.Created.String()))
+	img.ID = "sha256:" + hex.EncodeToString(hash[:])
+
+	// The tags move to the new image
+	for _, other := range images {
+		other.Tags = removeTags(other.Tags, img.Tags)
+	}
+	images[img.ID] = img
+
+	fmt.Println(img.ID)
+	return 0
+}
+
+// listImages prints the IDs of the images that match the filters.
+func listImages(images map[string]*image, params []string) int {
+	filters := []string{}
+	for i := 0; i < len(params); i++ {
+		if params[i] == "--filter" || params[i] == "-f" {
+			i++
+			filters = append(filters, params[i])
+		}
+	}
+
+	for _, img := range images {
+		if (&container{Labels: img.Labels}).matches(filters) {
+			fmt.Println(img.ID)
+		}
+	}
+	return 0
+}
+
+// inspectImages prints the images as a JSON array.
+func inspectImages(images map[string]*image, params []string) int {
+	data := []map[string]interface{}{}
+	for _, param := range params {
+		if strings.HasPrefix(param, "-") {
+			continue
+		}
+
+		img, ok := lookupImage(images, param)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: no such image %s\n", param)
+			return 125
+		}
+
+		data = append(data, map[string]interface{}{
+			"Id":       img.ID,
+			"RepoTags": img.Tags,
+			"Created":  img.Created,
+			"Size":     16 << 20,
+			"Config":   map[string]interface{}{"Labels": img.Labels},
+		})
+	}
+
+	out, _ := json.MarshalIndent(data, "", "    ")
+	fmt.Println(string(out))
+	return 0
+}
+
+// removeImages deletes the images passed as arguments.
+func removeImages(images map[string]*image, params []string) int {
+	for _, param := range params {
+		if strings.HasPrefix(param, "-") {
+			continue
+		}
+
+		img, ok := lookupImage(images, param)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: %s: image not known\n", param)
+			return 1
+		}
+		delete(images, img.ID)
+	}
+	return 0
+}
+
+// lookupImage finds an image using its ID (with or without "sha256:") or one of its tags.
+func lookupImage(images map[string]*image, ref string) (*image, bool) {
+	for _, img := range images {
+		if ref != "" && (strings.HasPrefix(img.ID, ref) || strings.HasPrefix(strings.TrimPrefix(img.ID, "sha256:"), ref)) {
+			return img, true
+		}
+		for _, tag := range img.Tags {
+			if tag == ref {
+				return img, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// removeTags returns the tags that aren't on remove.
+func removeTags(tags []string, remove []string) []string {
+	kept := []string{}
+	for _, tag := range tags {
+		found := false
+		for _, r := range remove {
+			found = found || tag == r
+		}
+		if !found {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
+
+// printTemplate prints each item using a Go template.
+func printTemplate(format string, data []map[string]interface{}) int {
+	tmpl, err := template.New("format").Parse(format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		return 125
+	}
+
+	for _, item := range data {
+		if err := tmpl.Execute(os.Stdout, item); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			return 125
+		}
+		fmt.Println()
+	}
+	return 0
+}
+
+// matches checks the "label=key[=value]" filters of "ps". Other filters are ignored.
+func (c *container) matches(filters []string) bool {
+	for _, filter := range filters {
+		kind, label, _ := strings.Cut(filter, "=")
+		if kind != "label" {
+			continue
+		}
+
+		key, value, hasValue := strings.Cut(label, "=")
+		current, ok := c.Labels[key]
+		if !ok || hasValue && current != value {
+			return false
+		}
+	}
+	return true
+}
+
+// inspectData returns the container in a format similar to "podman inspect".
+func (c *container) inspectData() map[string]interface{} {
+	status := "exited"
+	if c.Running {
+		status = "running"
+	}
+
+	mounts := []map[string]interface{}{}
+	for _, m := range c.Mounts {
+		mounts = append(mounts, map[string]interface{}{"Type": "bind", "Source": m.Source, "Destination": m.Destination, "RW": true})
+	}
+
+	return map[string]interface{}{
+		"Id":      c.ID,
+		"Name":    c.Name,
+		"Created": c.Created,
+		"Image":   c.Image,
+		"State": map[string]interface{}{
+			"Status":     status,
+			"Running":    c.Running,
+			"ExitCode":   0,
+			"StartedAt":  c.Started,
+			"FinishedAt": c.Stopped,
+		},
+		"SizeRw":     4096,
+		"SizeRootFs": 8 << 20,
+		"Config": map[string]interface{}{
+			"Image":  c.Image,
+			"Labels": c.Labels,
+		},
+		"Mounts": mounts,
+	}
+}
+
+// lookup finds a container using its name or (a prefix of) its ID.
+func lookup(containers map[string]*container, ref string) (*container, bool) {
+	if cont, ok := containers[ref]; ok {
+		return cont, true
+	}
+
+	for _, cont := range containers {
+		if ref != "" && strings.HasPrefix(cont.ID, ref) {
+			return cont, true
+		}
+	}
+	return nil, false
+}
+
+// firstArg returns the first argument or an empty string.
+func firstArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+// lastArg returns the last argument or an empty string.
+func lastArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[len(args)-1]
+}
@@ -0,0 +1,227 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fake implements a fake container engine used for hermetic tests.
+//
+// The fake engine is the test binary itself: call Main() at the start of TestMain and
+// point podman.New() (or the config's podman path) to Recorder.Path(). When the binary is
+// executed with the DEVELBOX_FAKE_ENGINE variable set, it behaves like a container engine:
+// it records every argument vector into a log and replays canned outputs.
+package fake
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EnvVar is the environment variable that points to the directory where the fake engine keeps its state.
+const EnvVar = "DEVELBOX_FAKE_ENGINE"
+
+const (
+	logFile       = "calls.jsonl"
+	responsesFile = "responses.json"
+	stateFile     = "containers.json"
+	imagesFile    = "images.json"
+)
+
+// Call is an argument vector received by the fake engine (without the executable path).
+type Call struct {
+	Args []string `json:"args"`
+}
+
+// Subcommand returns the engine subcommand of the call, for example "run" or "exec".
+func (c Call) Subcommand() string {
+	return Subcommand(c.Args)
+}
+
+// Has checks if the call contains the argument.
+func (c Call) Has(arg string) bool {
+	for _, v := range c.Args {
+		if v == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPair checks if the call contains a flag followed by the value (for example: "--label", "develbox_container=1").
+func (c Call) HasPair(flag, value string) bool {
+	for i := 0; i < len(c.Args)-1; i++ {
+		if c.Args[i] == flag && c.Args[i+1] == value {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the call as a space separated string.
+func (c Call) String() string {
+	return strings.Join(c.Args, " ")
+}
+
+// Response is a canned output that the fake engine replays for a subcommand.
+type Response struct {
+	Output   string `json:"output"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// Subcommand returns the subcommand on an argument vector. Management commands like
+// "container inspect" return the second word ("inspect").
+func Subcommand(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	if args[0] == "container" && len(args) > 1 {
+		return args[1]
+	}
+	return args[0]
+}
+
+// Recorder manages the state directory of the fake engine.
+type Recorder struct {
+	dir string
+}
+
+// New creates the state directory and exports DEVELBOX_FAKE_ENGINE so child processes act as the fake engine.
+func New(dir string) (*Recorder, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &Recorder{dir: dir}
+	if err := rec.Reset(); err != nil {
+		return nil, err
+	}
+
+	return rec, os.Setenv(EnvVar, dir)
+}
+
+// Path returns the path that has to be used as the engine executable.
+func (r *Recorder) Path() string {
+	path, err := os.Executable()
+	if err != nil {
+		return os.Args[0]
+	}
+	return path
+}
+
+// Reset removes every recorded call, canned response, container and image.
+func (r *Recorder) Reset() error {
+	for _, file := range []string{logFile, responsesFile, stateFile, imagesFile} {
+		err := os.Remove(filepath.Join(r.dir, file))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClearCalls removes the recorded calls but keeps the containers and responses.
+func (r *Recorder) ClearCalls() error {
+	err := os.Remove(filepath.Join(r.dir, logFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Reply sets the canned output for a subcommand (for example: "--version", "ps" or "inspect").
+func (r *Recorder) Reply(subcommand string, output string, exitCode int) error {
+	responses, err := readResponses(r.dir)
+	if err != nil {
+		return err
+	}
+
+	responses[subcommand] = Response{Output: output, ExitCode: exitCode}
+	return writeJSON(filepath.Join(r.dir, responsesFile), responses)
+}
+
+// Forget removes the canned output of a subcommand, so it's emulated again.
+func (r *Recorder) Forget(subcommand string) error {
+	responses, err := readResponses(r.dir)
+	if err != nil {
+		return err
+	}
+
+	delete(responses, subcommand)
+	return writeJSON(filepath.Join(r.dir, responsesFile), responses)
+}
+
+// Calls returns every call received by the fake engine in order.
+func (r *Recorder) Calls() ([]Call, error) {
+	data, err := os.ReadFile(filepath.Join(r.dir, logFile))
+	if os.IsNotExist(err) {
+		return []Call{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	calls := []Call{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var call Call
+		if err := json.Unmarshal([]byte(line), &call); err != nil {
+			return nil, err
+		}
+		calls = append(calls, call)
+	}
+	return calls, nil
+}
+
+// Find returns every call that used the subcommand.
+func (r *Recorder) Find(subcommand string) ([]Call, error) {
+	calls, err := r.Calls()
+	if err != nil {
+		return nil, err
+	}
+
+	found := []Call{}
+	for _, call := range calls {
+		if call.Subcommand() == subcommand {
+			found = append(found, call)
+		}
+	}
+	return found, nil
+}
+
+// readResponses reads the canned responses saved on dir.
+func readResponses(dir string) (map[string]Response, error) {
+	responses := map[string]Response{}
+	data, err := os.ReadFile(filepath.Join(dir, responsesFile))
+	if os.IsNotExist(err) {
+		return responses, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return responses, json.Unmarshal(data, &responses)
+}
+
+// writeJSON encodes v into path.
+func writeJSON(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
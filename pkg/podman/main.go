@@ -12,24 +12,68 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package podman is a wrapper around os/exec to run podman commands.
+// Package podman is a wrapper around os/exec to run container engine commands (podman, docker and nerdctl).
 package podman
 
 import (
-	"errors"
-	"fmt"
-	"os"
 	"os/exec"
-	"regexp"
-	"strconv"
+	"path/filepath"
 	"strings"
 
+	"github.com/kadmuffin/develbox/pkg/config"
 	"github.com/kpango/glg"
 )
 
-// Podman is a struct that saves the path to the podman executable.
-type Podman struct {
-	path string
+const (
+	// KindPodman is the engine kind for podman
+	KindPodman = "podman"
+	// KindDocker is the engine kind for docker
+	KindDocker = "docker"
+	// KindNerdctl is the engine kind for nerdctl (containerd)
+	KindNerdctl = "nerdctl"
+)
+
+// Engine is the interface that every container engine has to implement.
+type Engine interface {
+	// Kind returns the engine kind (for example: "podman").
+	Kind() string
+	// Path returns the path to the engine executable.
+	Path() string
+
+	// Create creates a container using the arguments provided.
+	Create(args []string, attach Attach) *exec.Cmd
+	// Exec executes a command inside a running container.
+	Exec(args []string, envVars map[string]string, sh bool, root bool, attach Attach) *exec.Cmd
+	// Start starts a container. The first argument has to be the container's name/id.
+	Start(args []string, attach Attach) error
+	// Stop stops a container. The first argument has to be the container's name/id.
+	Stop(args []string, attach Attach) error
+	// Remove stops and removes a container. The first argument has to be the container's name/id.
+	Remove(args []string, attach Attach) error
+	// Inspect returns the information of a container. Returns ErrNotFound if it doesn't exist.
+	Inspect(name string) (ContainerInfo, error)
+	// List returns the containers (including their size) that have every label ("key" or "key=value").
+	// Stopped containers are only included if all is true.
+	List(all bool, labels []string) ([]ContainerInfo, error)
+	// ListImages returns the images that have every label ("key" or "key=value").
+	ListImages(labels []string) ([]ImageInfo, error)
+	// Commit commits the container to an image.
+	Commit(args []string, attach Attach) *exec.Cmd
+	// Build builds a new image from the context on path.
+	Build(path string, tag string, attach Attach) *exec.Cmd
+	// Exists returns a boolean that indicates if the container was found.
+	Exists(name string) bool
+	// IsRunning checks if the container is running.
+	IsRunning(name string) bool
+	// Version gets the current engine version.
+	Version() (major, minor, patch int64, err error)
+
+	// Copy copies files into the container.
+	Copy(args []string, attach Attach) *exec.Cmd
+	// Attach attaches to the container.
+	Attach(args []string, attach Attach) *exec.Cmd
+	// RawCommand runs any engine subcommand (for example: ps).
+	RawCommand(args []string, attach Attach) *exec.Cmd
 }
 
 // Attach is config struct that sets the Stdin, Stdout and Stderr
@@ -44,212 +88,78 @@ type Attach struct {
 	PseudoTTY bool
 }
 
-// New creates a new Podman struct with the path to the podman executable.
-func New(path string) Podman {
-	glg.Infof("Podman path set to '%s'.", path)
-	cmd := exec.Command(path, "--version")
-	glg.Infof("Verifying that podman exists using '%s'.", cmd.String())
-
-	if err := cmd.Run(); err != nil {
-		glg.Fatalf("Can't access the podman executable: %s", err)
-	}
+// Constructor creates an engine that uses the executable at path.
+type Constructor func(path string) Engine
 
-	return Podman{path: path}
+// engines contains the constructors for every supported engine kind.
+var engines = map[string]Constructor{
+	KindPodman:  NewPodman,
+	KindDocker:  NewDocker,
+	KindNerdctl: NewNerdctl,
 }
 
-// cmd is private function that manages the command creation. Created as a boilerplate for other public functions.
-func (e *Podman) cmd(args []string, attach Attach) *exec.Cmd {
-	cmd := exec.Command(e.path, args...)
-
-	if attach.Stdin {
-		cmd.Stdin = os.Stdin
-	}
-	if attach.Stdout {
-		cmd.Stdout = os.Stdout
-	}
-	if attach.Stderr {
-		cmd.Stderr = os.Stderr
-	}
-
-	return cmd
+// Register adds (or replaces) the constructor for an engine kind. Useful for swapping the engine on tests.
+func Register(kind string, constructor Constructor) {
+	engines[kind] = constructor
 }
 
-// Create creates a container using the arguments provided.
-func (e *Podman) Create(args []string, attach Attach) *exec.Cmd {
-	params := []string{"run", "-t", "--init"}
-	params = append(params, args...)
-
-	return PrintCommandR("Creating container using the following command: %s", e.cmd(params, attach))
-
+// New creates a new Engine using the executable at path. The kind of engine is detected from the executable name.
+func New(path string) Engine {
+	return NewEngine("", path)
 }
 
-// Exec executes a command inside a running container and attaches (Stdin, Stdout) if "attach" is true.
-func (e *Podman) Exec(args []string, envVars map[string]string, sh bool, root bool, attach Attach) *exec.Cmd {
-	uid := os.Getuid()
-	params := []string{"exec", "-i"}
-
-	if attach.PseudoTTY {
-		params = append(params, "-t")
-	}
-
-	if attach == *new(Attach) {
-		params = append(params, "-d")
-	}
-
-	for k, v := range envVars {
-		params = append(params, "-e", fmt.Sprintf("%s=%s", k, ReplaceEnvVars(v)))
-	}
-
-	if root {
-		params = append(params, "--user", "0:0")
-	} else {
-		params = append(params, "--user", fmt.Sprintf("%d:%d", uid, uid))
+// NewEngine creates a new Engine of the given kind. If kind is empty, it's detected from the executable name.
+func NewEngine(kind string, path string) Engine {
+	if kind == "" {
+		kind = DetectKind(path)
 	}
 
-	params = append(params, args[0])
-
-	if sh {
-		params = append(params, "sh", "-c")
+	constructor, ok := engines[kind]
+	if !ok {
+		glg.Fatalf("Unsupported container engine '%s'.", kind)
 	}
 
-	params = append(params, args[1:]...)
-
-	return PrintCommandR("Executing command: %s", e.cmd(params, attach))
-}
-
-// Exists returns a boolean that indicates if the container was found.
-func (e *Podman) Exists(name string) bool {
-	params := []string{"container", "exists", name}
-
-	// Docker doesn't have an exists function, so this is
-	// the closest thing I could find.
-	if e.IsDocker() {
-		//params = []string{"inspect", name}
-		out, err := e.cmd([]string{"ps", "-a", "--format", "{{.Names}}"}, Attach{}).CombinedOutput()
-		if err != nil {
-			glg.Fatalf("Failed to check if container exists: %s", string(out))
-		}
-
-		return strings.Contains(string(out), name)
-	}
-
-	_, err := e.cmd(params, Attach{}).CombinedOutput()
-	return err == nil
-}
-
-// Start starts a container and returns an error in case of failure. The first argument has to be the container's name/id.
-func (e *Podman) Start(args []string, attach Attach) error {
-	params := []string{"start"}
-	params = append(params, args...)
-
-	return PrintCommandR("Starting container using the following arguments:\n  - %s", e.cmd(params, attach)).Run()
-}
-
-// Stop stops a container and returns an error in case of failure. In arguments, the first argument has to be the container's name/id if no flag are added before of the name.
-func (e *Podman) Stop(args []string, attach Attach) error {
-	params := []string{"stop"}
-	params = append(params, args...)
-
-	return PrintCommandR("Stopping container using the following arguments:\n  - %s", e.cmd(params, attach)).Run()
-}
-
-// Remove removes a container and returns an error in case of failure. In arguments, the first argument has to be the container's name/id if no flag are added before of the name.
-func (e *Podman) Remove(args []string, attach Attach) error {
-	e.Stop(args, attach)
-
-	params := []string{"rm"}
-	params = append(params, args...)
-
-	return PrintCommandR("Removing container using the following arguments:\n  - %s", e.cmd(params, attach)).Run()
+	glg.Infof("Using the '%s' container engine.", kind)
+	return constructor(path)
 }
 
-// Copy copies files into the container
-func (e *Podman) Copy(args []string, attach Attach) *exec.Cmd {
-	e.Stop(args, attach)
-
-	params := []string{"cp"}
-	params = append(params, args...)
-
-	return PrintCommandR("Running copy using the following arguments:\n  - %s", e.cmd(params, attach))
-}
-
-// IsDocker checks if the podman path contains the word "docker". Mainly here so we can make this work on docker too.
-func (e *Podman) IsDocker() bool {
-	return strings.Contains(e.path, "docker")
-}
-
-// Version gets the current podman version
-func (e *Podman) Version() (major, minor, patch int64, err error) {
-	data, err := e.cmd([]string{"--version"}, Attach{}).Output()
-	if err != nil {
-		return 0, 0, 0, err
-	}
-
-	regex, err := regexp.Compile(`([0-9]+)\.([0-9]+)\.([0-9]+)([0-9a-zA-z-\.]+)*`)
-	if err != nil {
-		return 0, 0, 0, err
-	}
-
-	parsed := regex.FindStringSubmatch(string(data))
-
-	if parsed == nil {
-		return 0, 0, 0, errors.New("unable to parse version")
+// FromConfig creates a new Engine using the podman section of the config.
+//
+// If the API is enabled and the socket answers, the engine uses the REST API and falls back to the CLI.
+func FromConfig(cfg config.Podman) Engine {
+	engine := NewEngine(cfg.Engine, cfg.Path)
+	if !cfg.API {
+		return engine
 	}
 
-	major, err = strconv.ParseInt(parsed[1], 10, 0)
-	if err != nil {
-		return 0, 0, 0, err
+	socket := cfg.Socket
+	if socket == "" {
+		socket = DefaultSocket(engine.Kind())
 	}
 
-	minor, err = strconv.ParseInt(parsed[2], 10, 0)
-	if err != nil {
-		return 0, 0, 0, err
+	if socket == "" || !config.FileExists(socket) {
+		glg.Warnf("Couldn't find the API socket for %s, using the CLI instead.", engine.Kind())
+		return engine
 	}
 
-	patch, err = strconv.ParseInt(parsed[3], 10, 0)
-	if err != nil {
-		return 0, 0, 0, err
+	api := NewAPI(engine, socket)
+	if err := api.Client().Ping(); err != nil {
+		glg.Warnf("The API socket '%s' isn't answering, using the CLI instead. %s", socket, err)
+		return engine
 	}
-
-	return major, minor, patch, nil
+	return api
 }
 
-// Build builds a new image using Podman
-func (e *Podman) Build(path string, tag string, attach Attach) *exec.Cmd {
-	params := []string{"build"}
-	params = append(params, tag)
+// DetectKind guesses the engine kind using the name of the executable. Defaults to podman.
+func DetectKind(path string) string {
+	name := filepath.Base(path)
 
-	return PrintCommandR("Running build using the following arguments:\n  - %s", e.cmd(params, attach))
-}
-
-// Attach attaches to the podman container
-func (e *Podman) Attach(args []string, attach Attach) *exec.Cmd {
-	params := []string{"attach"}
-	params = append(params, args...)
-
-	return PrintCommandR("Running attach using the following arguments:\n  - %s", e.cmd(params, attach))
-}
-
-// RawCommand runs any podman subcommand (for example: ps)
-func (e *Podman) RawCommand(args []string, attach Attach) *exec.Cmd {
-	return e.cmd(args, attach)
-}
-
-// IsRunning checks if the container is running
-func (e *Podman) IsRunning(name string) bool {
-	params := []string{"container", "inspect", "-f", "'{{.State.Running}}'", name}
-	data, err := e.cmd(params, Attach{}).Output()
-	if err != nil {
-		return false
+	switch {
+	case strings.Contains(name, KindDocker):
+		return KindDocker
+	case strings.Contains(name, KindNerdctl):
+		return KindNerdctl
+	default:
+		return KindPodman
 	}
-
-	return strings.Contains(string(data), "true")
-}
-
-// Commit commits the container to an image
-func (e *Podman) Commit(args []string, attach Attach) *exec.Cmd {
-	params := []string{"commit"}
-	params = append(params, args...)
-
-	return PrintCommandR("Running commit using the following arguments:\n  - %s", e.cmd(params, attach))
 }
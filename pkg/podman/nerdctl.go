@@ -0,0 +1,30 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podman
+
+// Nerdctl is the engine that runs containers on containerd using the nerdctl executable.
+type Nerdctl struct {
+	cli
+}
+
+// NewNerdctl creates a new Nerdctl engine with the path to the nerdctl executable.
+func NewNerdctl(path string) Engine {
+	return &Nerdctl{cli: newCLI(path)}
+}
+
+// Kind returns the engine kind
+func (e *Nerdctl) Kind() string {
+	return KindNerdctl
+}
@@ -0,0 +1,38 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podman
+
+// Podman is the engine that runs containers using the podman executable.
+type Podman struct {
+	cli
+}
+
+// NewPodman creates a new Podman engine with the path to the podman executable.
+func NewPodman(path string) Engine {
+	return &Podman{cli: newCLI(path)}
+}
+
+// Kind returns the engine kind
+func (e *Podman) Kind() string {
+	return KindPodman
+}
+
+// Exists returns a boolean that indicates if the container was found.
+func (e *Podman) Exists(name string) bool {
+	params := []string{"container", "exists", name}
+
+	_, err := e.cmd(params, Attach{}).CombinedOutput()
+	return err == nil
+}
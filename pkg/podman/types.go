@@ -0,0 +1,127 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podman
+
+import (
+	"strings"
+	"time"
+)
+
+// ContainerState is the state of a container as reported by inspect.
+type ContainerState struct {
+	// Status is the current status (for example: "running" or "exited")
+	Status string `json:"Status"`
+	// Running is true if the container is running
+	Running bool `json:"Running"`
+	// ExitCode is the exit code of the last run
+	ExitCode int `json:"ExitCode"`
+	// StartedAt is the last time the container was started
+	StartedAt time.Time `json:"StartedAt"`
+	// FinishedAt is the last time the container stopped
+	FinishedAt time.Time `json:"FinishedAt"`
+}
+
+// ContainerConfig is the configuration that the container was created with.
+type ContainerConfig struct {
+	// Image is the image reference used to create the container
+	Image string `json:"Image"`
+	// Labels contains the labels of the container
+	Labels map[string]string `json:"Labels"`
+}
+
+// Mount is a mount of a container.
+type Mount struct {
+	Type        string `json:"Type"`
+	Source      string `json:"Source"`
+	Destination string `json:"Destination"`
+	RW          bool   `json:"RW"`
+}
+
+// ContainerInfo is the result of inspecting a container.
+type ContainerInfo struct {
+	ID      string          `json:"Id"`
+	Name    string          `json:"Name"`
+	Created time.Time       `json:"Created"`
+	ImageID string          `json:"Image"`
+	State   ContainerState  `json:"State"`
+	Config  ContainerConfig `json:"Config"`
+	Mounts  []Mount         `json:"Mounts"`
+
+	// SizeRw is the size of the files changed by the container (only set when listing)
+	SizeRw int64 `json:"SizeRw"`
+	// SizeRootFs is the total size of the container files (only set when listing)
+	SizeRootFs int64 `json:"SizeRootFs"`
+}
+
+// Label returns the value of a label without the quotes added by older develbox versions.
+func (c *ContainerInfo) Label(key string) string {
+	return strings.Trim(c.Config.Labels[key], "\"")
+}
+
+// LastUsed returns the last time the container was running. Returns the current time if it's running.
+func (c *ContainerInfo) LastUsed() time.Time {
+	if c.State.Running {
+		return time.Now()
+	}
+
+	last := c.Created
+	for _, t := range []time.Time{c.State.StartedAt, c.State.FinishedAt} {
+		if t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+// ContainerSummary is an item on the list of containers.
+type ContainerSummary struct {
+	ID         string            `json:"Id"`
+	Names      []string          `json:"Names"`
+	Image      string            `json:"Image"`
+	ImageID    string            `json:"ImageID"`
+	State      string            `json:"State"`
+	Status     string            `json:"Status"`
+	Labels     map[string]string `json:"Labels"`
+	Created    int64             `json:"Created"`
+	SizeRw     int64             `json:"SizeRw"`
+	SizeRootFs int64             `json:"SizeRootFs"`
+}
+
+// Name returns the first name of the container without the leading "/" that docker adds.
+func (c *ContainerSummary) Name() string {
+	if len(c.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// Label returns the value of a label without the quotes added by older develbox versions.
+func (c *ContainerSummary) Label(key string) string {
+	return strings.Trim(c.Labels[key], "\"")
+}
+
+// ImageInfo is the result of inspecting an image.
+type ImageInfo struct {
+	ID       string          `json:"Id"`
+	RepoTags []string        `json:"RepoTags"`
+	Created  time.Time       `json:"Created"`
+	Size     int64           `json:"Size"`
+	Config   ContainerConfig `json:"Config"`
+}
+
+// Label returns the value of a label without the quotes added by older develbox versions.
+func (i *ImageInfo) Label(key string) string {
+	return strings.Trim(i.Config.Labels[key], "\"")
+}
@@ -0,0 +1,198 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/podman"
+)
+
+// startAPIStub starts an HTTP server on a unix socket that answers like the engine's REST API
+func startAPIStub(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "dbx-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(dir, "api.sock")
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := "/" + podman.APIVersion
+	mux := http.NewServeMux()
+	mux.HandleFunc(prefix+"/_ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+	mux.HandleFunc(prefix+"/version", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"Version": "4.3.1"})
+	})
+	mux.HandleFunc(prefix+"/containers/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case prefix + "/containers/develbox-test/json":
+			w.Write([]byte(`{"Id": "abc", "Name": "/develbox-test", "State": {"Status": "running", "Running": true},
+				"Config": {"Image": "alpine:edge", "Labels": {"develbox_container": "1"}}}`))
+		case prefix + "/containers/develbox-test/exec":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if fmt.Sprint(body["Cmd"]) != "[sh -c echo hello]" {
+				http.Error(w, `{"message": "unexpected command"}`, http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte(`{"Id": "exec1"}`))
+		case prefix + "/containers/json":
+			if r.URL.Query().Get("filters") != `{"label":["develbox_container=1"]}` {
+				http.Error(w, `{"message": "missing filter"}`, http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`[{"Id": "abc", "Names": ["/develbox-test"], "State": "running",
+				"Labels": {"develbox_project_path": "\"/code\""}, "SizeRw": 42}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "no such container"}`))
+		}
+	})
+	mux.HandleFunc(prefix+"/exec/exec1/start", func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		buf.WriteString("HTTP/1.1 101 UPGRADED\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+		writeFrame(buf, 1, "hello\n")
+		writeFrame(buf, 2, "warning\n")
+		buf.Flush()
+	})
+	mux.HandleFunc(prefix+"/exec/exec1/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ExitCode": 3}`))
+	})
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+
+	t.Cleanup(func() {
+		server.Close()
+		os.RemoveAll(dir)
+	})
+	return socket
+}
+
+// writeFrame writes a frame of the multiplexed stream that the API uses for exec
+func writeFrame(buf interface{ Write([]byte) (int, error) }, stream byte, data string) {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+	buf.Write(header)
+	buf.Write([]byte(data))
+}
+
+// TestAPIEngine tests the REST API backend against a stub server
+func TestAPIEngine(t *testing.T) {
+	socket := startAPIStub(t)
+
+	cfg := SampleConfig.Podman
+	cfg.API = true
+	cfg.Socket = socket
+
+	engine := podman.FromConfig(cfg)
+	api, ok := engine.(*podman.API)
+	if !ok {
+		t.Fatalf("Expected the API engine, got %T", engine)
+	}
+
+	if !api.Exists(testContainerName) || api.Exists("develbox-missing") {
+		t.Errorf("Exists() doesn't match the containers on the API")
+	}
+
+	if !api.IsRunning(testContainerName) {
+		t.Errorf("IsRunning() returned false for a running container")
+	}
+
+	major, minor, patch, err := api.Version()
+	if err != nil || major != 4 || minor != 3 || patch != 1 {
+		t.Errorf("Version() returned %d.%d.%d (%v)", major, minor, patch, err)
+	}
+
+	containers, err := api.Client().List(true, []string{"develbox_container=1"})
+	if err != nil {
+		t.Fatalf("Failed to list containers: %s", err)
+	}
+	if len(containers) != 1 || containers[0].Name() != testContainerName || containers[0].Label("develbox_project_path") != "/code" {
+		t.Errorf("Unexpected container list: %+v", containers)
+	}
+
+	var stdout, stderr bytes.Buffer
+	opts := podman.NewExecOptions([]string{"echo hello"}, map[string]string{}, true, false)
+	code, err := api.ExecStream(testContainerName, opts, nil, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("Failed to exec: %s", err)
+	}
+	if code != 3 || stdout.String() != "hello\n" || stderr.String() != "warning\n" {
+		t.Errorf("Unexpected exec result: code %d, stdout %q, stderr %q", code, stdout.String(), stderr.String())
+	}
+}
+
+// TestAPIFallback checks that the CLI is used when the socket doesn't exist
+func TestAPIFallback(t *testing.T) {
+	cfg := config.Podman{Path: podmanPath, API: true, Socket: "/nonexistent/develbox.sock"}
+
+	if _, ok := podman.FromConfig(cfg).(*podman.API); ok {
+		t.Errorf("Expected the CLI engine when the socket doesn't exist")
+	}
+}
+
+// TestAPINotFound checks that only the routes of a container return ErrNotFound for a 404
+func TestAPINotFound(t *testing.T) {
+	dir, err := os.MkdirTemp("", "dbx-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Like a socket of something that isn't the engine, every route is missing
+	socket := filepath.Join(dir, "api.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.NewServeMux()}
+	go server.Serve(listener)
+	defer server.Close()
+
+	client := podman.NewAPIClient(socket)
+	var apiErr *podman.APIError
+	if err := client.Ping(); err == podman.ErrNotFound || !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected the 404 of the ping to be an API error, got %v", err)
+	}
+	if _, err := client.Inspect(testContainerName); err != podman.ErrNotFound {
+		t.Errorf("Expected ErrNotFound when inspecting, got %v", err)
+	}
+	if err := client.Remove(testContainerName, true); err != podman.ErrNotFound {
+		t.Errorf("Expected ErrNotFound when removing, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kadmuffin/develbox/cmd"
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/container"
+	"github.com/kadmuffin/develbox/pkg/pkgm"
+)
+
+// TestSetFlag tests that --set isn't passed to the package manager by the commands that don't parse their flags
+func TestSetFlag(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+	Setup(false, true)
+	defer func() { config.Sets = []string{} }()
+
+	cfg := SampleConfig
+	cfg.Packages = append([]string{}, SampleConfig.Packages...)
+	if err := config.Write(&cfg); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+	engine.ClearCalls()
+
+	args := []string{"--set", "container.shell=/bin/zsh", "add", "--set=podman.privileged=false", "curl"}
+	if err := cmd.ExecuteArgs(args); err != nil {
+		t.Fatalf("Failed to run develbox %s: %s", strings.Join(args, " "), err)
+	}
+	if len(config.Sets) != 2 {
+		t.Errorf("Expected both --set flags to be parsed, got %v", config.Sets)
+	}
+
+	calls, _ := engine.Find("exec")
+	installed := false
+	for _, call := range calls {
+		if strings.Contains(call.String(), "--set") || strings.Contains(call.String(), "container.shell") {
+			t.Errorf("Expected --set to not reach the package manager, got %s", call)
+		}
+		installed = installed || strings.Contains(call.String(), "apk add curl")
+	}
+	if !installed {
+		t.Errorf("Expected curl to be installed, got %v", calls)
+	}
+
+	written, err := config.Read()
+	if err != nil {
+		t.Fatalf("Failed to read config file: %s", err)
+	}
+	if !pkgm.ContainsPackage(written.Packages, "curl") || len(written.Packages) != len(SampleConfig.Packages)+1 {
+		t.Errorf("Expected only curl to be added to the config, got %v", written.Packages)
+	}
+	if written.Container.Shell != "/bin/zsh" || written.Podman.Privileged {
+		t.Errorf("Expected the overrides to be used, got %+v", written.Container)
+	}
+}
+
+// TestProfileFlag tests that --profile selects the container of the profile and isn't passed to the package manager
+func TestProfileFlag(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+	Setup(false, false)
+	defer func() { config.ProfileName = "" }()
+
+	cfg := SampleConfig
+	cfg.Packages = append([]string{}, SampleConfig.Packages...)
+	cfg.Profiles = map[string]config.Profile{"ci": {"podman": map[string]interface{}{"privileged": false}}}
+	if err := config.Write(&cfg); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+
+	config.ProfileName = "ci"
+	profileCfg, err := config.Read()
+	if err != nil {
+		t.Fatalf("Failed to read config file: %s", err)
+	}
+	if err := container.Create(profileCfg, true); err != nil {
+		t.Fatalf("Failed to create the container of the profile: %s", err)
+	}
+	config.ProfileName = ""
+	engine.ClearCalls()
+
+	args := []string{"--profile", "ci", "add", "vim"}
+	if err := cmd.ExecuteArgs(args); err != nil {
+		t.Fatalf("Failed to run develbox %s: %s", strings.Join(args, " "), err)
+	}
+
+	calls, _ := engine.Find("exec")
+	installed := false
+	for _, call := range calls {
+		if call.Has("--profile") || strings.Contains(call.String(), "add --profile") {
+			t.Errorf("Expected --profile to not reach the package manager, got %s", call)
+		}
+		installed = installed || (call.Has(testContainerName+"-ci") && strings.Contains(call.String(), "apk add vim"))
+	}
+	if !installed {
+		t.Errorf("Expected vim to be installed on %s-ci, got %v", testContainerName, calls)
+	}
+
+	written, err := config.Read()
+	if err != nil {
+		t.Fatalf("Failed to read config file: %s", err)
+	}
+	if !pkgm.ContainsPackage(written.Packages, "vim") || pkgm.ContainsPackage(written.Packages, "ci") {
+		t.Errorf("Expected only vim to be added to the config, got %v", written.Packages)
+	}
+}
+
+// TestWithFlag tests that only --with selects the package manager, short flags are passed to the package manager
+func TestWithFlag(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+	Setup(false, true)
+
+	cfg := SampleConfig
+	cfg.Packages = append([]string{}, SampleConfig.Packages...)
+	if err := config.Write(&cfg); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+	engine.ClearCalls()
+
+	if err := cmd.ExecuteArgs([]string{"add", "-w", "curl"}); err != nil {
+		t.Fatalf("Failed to run develbox add -w curl: %s", err)
+	}
+
+	calls, _ := engine.Find("exec")
+	passed := false
+	for _, call := range calls {
+		command := call.Args[len(call.Args)-1]
+		passed = passed || (strings.Contains(command, "apk add") && strings.Contains(command, "-w") && strings.Contains(command, "curl"))
+	}
+	if !passed {
+		t.Errorf("Expected -w to be passed to apk, got %v", calls)
+	}
+
+	written, err := config.Read()
+	if err != nil {
+		t.Fatalf("Failed to read config file: %s", err)
+	}
+	if !pkgm.ContainsPackage(written.Packages, "curl") {
+		t.Errorf("Expected curl to be added to the config, got %v", written.Packages)
+	}
+}
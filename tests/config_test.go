@@ -90,11 +90,6 @@ var (
 	}
 )
 
-func init() {
-	// Set the podman path
-	config.CheckDocker(&SampleConfig)
-}
-
 // TestConfig tests the read related functions of the config package
 func TestConfig(t *testing.T) {
 	t.Logf("Current location is %s", os.Getenv("PWD"))
@@ -204,3 +199,63 @@ func CompareConfigs(cfg1, cfg2 config.Structure) bool {
 	// Compare the two configs
 	return reflect.DeepEqual(cfg1, cfg2)
 }
+
+// TestKeepFormat tests that writing the config only changes the values that changed
+func TestKeepFormat(t *testing.T) {
+	Setup(false, false)
+
+	original := `{
+    "x-team": {"owner": "infra"},
+    "packages": [
+        "git"
+    ],
+    "container": {
+        "name": "develbox-format"
+    },
+    "image": {
+        "uri": "alpine:latest",
+        "pkgmanager": {"driver": "apk"}
+    }
+}
+`
+	if err := os.WriteFile(".develbox/config.json", []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+
+	_, err := config.Update(func(cfg *config.Structure) error {
+		cfg.Packages = append(cfg.Packages, "vim")
+		cfg.DevPackages = []string{"gcc"}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to update config file: %s", err)
+	}
+
+	expected := `{
+    "x-team": {"owner": "infra"},
+    "packages": [
+        "git",
+        "vim"
+    ],
+    "container": {
+        "name": "develbox-format"
+    },
+    "image": {
+        "uri": "alpine:latest",
+        "pkgmanager": {"driver": "apk"}
+    },
+    "devpackages": [
+        "gcc"
+    ]
+}
+`
+	written, _ := os.ReadFile(".develbox/config.json")
+	if string(written) != expected {
+		t.Errorf("Expected only the packages to change, got:\n%s", written)
+	}
+
+	compact, err := config.EditJSON([]byte(`{"a": 1, "b": {"c": [1]}}`), map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": []int{1}}}, map[string]interface{}{"b": map[string]interface{}{"c": []int{1, 2}}})
+	if err != nil || string(compact) != `{"b": {"c": [1,2]}}` {
+		t.Errorf("Expected the compact document to stay compact, got '%s' (%v)", compact, err)
+	}
+}
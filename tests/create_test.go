@@ -15,9 +15,13 @@
 package main_test
 
 import (
+	"fmt"
+	"os"
+	"os/exec"
 	"testing"
 
 	"github.com/kadmuffin/develbox/cmd"
+	"github.com/kadmuffin/develbox/pkg/config"
 	"github.com/kadmuffin/develbox/pkg/container"
 )
 
@@ -40,6 +44,147 @@ func TestCreate(t *testing.T) {
 	}
 }
 
+// TestCreateFlags checks the arguments that container.Create passes to the engine
+func TestCreateFlags(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+	Setup(false, false)
+	engine.ClearCalls()
+
+	container.PkgVersion = cmd.GetRootCLI().Version
+	err := container.Create(SampleConfig, true)
+	if err != nil {
+		t.Fatalf("Failed to create container: %s", err)
+	}
+
+	runs, err := engine.Find("run")
+	if err != nil {
+		t.Fatalf("Failed to read the fake engine calls: %s", err)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("Expected a single run call, got %d", len(runs))
+	}
+	run := runs[0]
+
+	cwd := config.GetCurrentDirectory()
+	pairs := [][2]string{
+		{"--name", testContainerName},
+		{"-e", "DEVELBOX_CONTAINER=1"},
+		{"--label", "develbox_container=1"},
+		{"--label", fmt.Sprintf("develbox_version=%s", container.PkgVersion)},
+		{"--mount", fmt.Sprintf("type=bind,source=%s,destination=/code,bind-propagation=rslave", cwd)},
+	}
+	for _, pair := range pairs {
+		if !run.HasPair(pair[0], pair[1]) {
+			t.Errorf("Missing '%s %s' on: %s", pair[0], pair[1], run)
+		}
+	}
+
+	args := []string{
+		"--net=host",
+		"--privileged",
+		"-w=/code",
+		fmt.Sprintf("--mount=type=bind,src=%s/.develbox/home,dst=/home/%s,bind-propagation=rslave", cwd, os.Getenv("USER")),
+	}
+	for _, arg := range args {
+		if !run.Has(arg) {
+			t.Errorf("Missing '%s' on: %s", arg, run)
+		}
+	}
+
+	// The image and the command have to be the last arguments
+	last := run.Args[len(run.Args)-2:]
+	if last[0] != SampleConfig.Image.URI || last[1] != "sh" {
+		t.Errorf("Expected the command to end with '%s sh', got: %s", SampleConfig.Image.URI, run)
+	}
+
+	// The packages are installed with a single exec call
+	install := fmt.Sprintf("apk add %s", "nodejs npm git make")
+	if !hasExec(t, install) {
+		t.Errorf("Packages weren't installed using '%s'", install)
+	}
+
+	removes, _ := engine.Find("rm")
+	commits, _ := engine.Find("commit")
+	if len(commits) != 0 {
+		t.Errorf("Container was commited without auto_commit: %v", commits)
+	}
+	// Only the old container is removed (because of deleteOld)
+	if len(removes) != 1 {
+		t.Errorf("Expected a single rm call, got %v", removes)
+	}
+}
+
+// TestCreateUnknownDriver tests that a container is still created when the driver of the package manager doesn't exist
+func TestCreateUnknownDriver(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+	Setup(false, false)
+
+	cfg := SampleConfig
+	cfg.Image.PkgManager.Driver = "aptt"
+	container.PkgVersion = cmd.GetRootCLI().Version
+	if err := container.Create(cfg, true); err != nil {
+		t.Fatalf("Failed to create container: %s", err)
+	}
+	if !ContainerExists(testContainerName) {
+		t.Errorf("Container %s does not exist", testContainerName)
+	}
+}
+
+// TestCreatePinnedGo tests that Go isn't installed again when the config has it with a version or a repo
+func TestCreatePinnedGo(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+
+	for _, pkg := range []string{"go@1.19", "community/go"} {
+		Setup(false, false)
+		engine.ClearCalls()
+
+		cfg := SampleConfig
+		cfg.Packages = append(append([]string{}, SampleConfig.Packages...), pkg)
+		container.PkgVersion = cmd.GetRootCLI().Version
+		if err := container.Create(cfg, true); err != nil {
+			t.Fatalf("Failed to create container: %s", err)
+		}
+		if hasExec(t, "apk add go") {
+			t.Errorf("Go was installed without a version next to %s", pkg)
+		}
+	}
+}
+
+// TestFakeEngineUsage tests that the fake engine fails like the real CLIs when it doesn't get a command
+func TestFakeEngineUsage(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+
+	for _, args := range [][]string{{}, {"container"}} {
+		err := exec.Command(podmanPath, args...).Run()
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 125 {
+			t.Errorf("Expected %v to exit with 125, got %v", args, err)
+		}
+	}
+}
+
+// hasExec checks if an exec call on the fake engine ran the command
+func hasExec(t *testing.T, command string) bool {
+	execs, err := engine.Find("exec")
+	if err != nil {
+		t.Fatalf("Failed to read the fake engine calls: %s", err)
+	}
+
+	for _, call := range execs {
+		if call.Args[len(call.Args)-1] == command {
+			return true
+		}
+	}
+	return false
+}
+
 // This function has been commented out because it basically does the same thing as TestCreate but with a different name
 // Only difference is that it reads the config from a file instead of using the SampleConfig variable
 // Which we are already testing in the config_test.go file
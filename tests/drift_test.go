@@ -0,0 +1,59 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kadmuffin/develbox/pkg/container"
+	"github.com/kadmuffin/develbox/pkg/podman"
+)
+
+// TestDrift tests that the changed config fields are detected using the container labels
+func TestDrift(t *testing.T) {
+	keepContainer = true
+	Setup(false, true)
+
+	info, err := podman.New(podmanPath).Inspect(testContainerName)
+	if err != nil {
+		t.Fatalf("Failed to inspect container: %s", err)
+	}
+
+	fields, known := container.Drift(info, SampleConfig)
+	if !known || len(fields) != 0 {
+		t.Errorf("Expected no drift, got %v (known: %t)", fields, known)
+	}
+
+	// An empty list is the same as a missing one
+	cfg := SampleConfig
+	cfg.Container.Mounts = nil
+	cfg.Container.Ports = nil
+	if fields, _ := container.Drift(info, cfg); len(fields) != 0 {
+		t.Errorf("Empty lists were reported as changed: %v", fields)
+	}
+
+	cfg.Image.URI = "alpine:latest"
+	cfg.Container.Ports = append([]string{"8080:8080"}, cfg.Container.Ports...)
+	fields, _ = container.Drift(info, cfg)
+	if !reflect.DeepEqual(fields, []string{"image.uri", "container.ports"}) {
+		t.Errorf("Expected image.uri and container.ports to change, got %v", fields)
+	}
+
+	info.Config.Labels = map[string]string{}
+	if _, known := container.Drift(info, cfg); known {
+		t.Errorf("Containers without the hash label can't be compared")
+	}
+}
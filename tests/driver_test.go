@@ -0,0 +1,67 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"testing"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/pkgm"
+)
+
+// TestDrivers tests the commands created by the built-in and custom drivers
+func TestDrivers(t *testing.T) {
+	custom := config.PackageManager{
+		Operations: config.Operations{
+			Add: "apt-get install {args} {-y} {--quiet}",
+		},
+	}
+
+	tests := []struct {
+		pkgm     config.PackageManager
+		opert    pkgm.Operation
+		expected string
+	}{
+		{config.PackageManager{Driver: "apt"}, pkgm.NewOperation("add", []string{"vim"}, []string{}, true), "apt-get -y install vim"},
+		{config.PackageManager{Driver: "apt"}, pkgm.NewOperation("add", []string{"vim"}, []string{}, false), "apt-get install vim"},
+		{config.PackageManager{Driver: "zypper"}, pkgm.NewOperation("del", []string{"vim"}, []string{"--clean-deps"}, true), "zypper -n remove --clean-deps vim"},
+		{config.PackageManager{Driver: "apk"}, pkgm.NewOperation("clean", []string{}, []string{}, true), "rm -rf /var/cache/apk/*"},
+		{config.PackageManager{Driver: "nix"}, pkgm.NewOperation("add", []string{"hello"}, []string{}, true), "nix-env -iA nixpkgs.hello"},
+		{config.PackageManager{Driver: "dnf"}, pkgm.NewOperation("query", []string{"vim"}, []string{}, true), "rpm -q --qf '%{NAME} %{VERSION}-%{RELEASE}\\n' vim"},
+		{custom, pkgm.NewOperation("add", []string{"vim"}, []string{}, true), "apt-get install vim -y --quiet"},
+		{custom, pkgm.NewOperation("add", []string{"vim"}, []string{}, false), "apt-get install vim  "},
+	}
+
+	for _, test := range tests {
+		command, err := test.opert.StringCommand(&test.pkgm)
+		if err != nil {
+			t.Fatalf("Failed to create the command: %s", err)
+		}
+		if command != test.expected {
+			t.Errorf("Expected '%s', got '%s'", test.expected, command)
+		}
+	}
+
+	opert := pkgm.NewOperation("add", []string{"vim"}, []string{}, true)
+	if _, err := opert.StringCommand(&config.PackageManager{Driver: "brew"}); err == nil {
+		t.Errorf("Expected an error for an unknown driver")
+	}
+
+	driver, _ := pkgm.GetDriver(&config.PackageManager{Driver: "xbps"})
+	versions := driver.Parse("ii nodejs-18.12.1_1 Evented I/O for V8 javascript\nii git-2.38.1_1 Git Tree History Storage Tool\n", []string{"nodejs"})
+	if versions["nodejs"] != "18.12.1_1" || len(versions) != 1 {
+		t.Errorf("Expected only nodejs 18.12.1_1, got %v", versions)
+	}
+}
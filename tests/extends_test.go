@@ -0,0 +1,113 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/pkgm"
+)
+
+// TestExtends tests that the configs on "extends" are merged and that writes only change the project config
+func TestExtends(t *testing.T) {
+	Setup(false, false)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/distro/base.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"image": {"uri": "alpine:latest", "pkgmanager": {"driver": "apk"}}, "packages": ["curl"]}`))
+	}))
+	defer server.Close()
+
+	source := config.PresetSource
+	config.PresetSource = server.URL
+	defer func() { config.PresetSource = source }()
+
+	files := map[string]string{
+		".develbox/base.yaml": "image:\n  uri: debian:bullseye\n  pkgmanager:\n    driver: apt\npackages: [git]\ncommands:\n  build: make\ncontainer:\n  binds:\n    xorg: false\n",
+		".develbox/config.json": `{
+	"extends": ["distro/base", "./base.yaml"],
+	"container": {"name": "develbox-extends"},
+	"commands": {"test": "make test"},
+	"packages": ["vim"]
+}
+`,
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %s", path, err)
+		}
+	}
+
+	cfg, err := config.Read()
+	if err != nil {
+		t.Fatalf("Failed to read config file: %s", err)
+	}
+	if !reflect.DeepEqual(cfg.Packages, []string{"curl", "git", "vim"}) {
+		t.Errorf("Expected the lists to be appended, got %v", cfg.Packages)
+	}
+	if cfg.Image.URI != "debian:bullseye" || cfg.Image.PkgManager.Driver != "apt" || cfg.Container.Binds.XOrg {
+		t.Errorf("Expected the values to be replaced by the later configs, got %+v", cfg.Image)
+	}
+	if cfg.Commands["build"] != "make" || cfg.Commands["test"] != "make test" {
+		t.Errorf("Expected the objects to be merged, got %v", cfg.Commands)
+	}
+
+	_, err = config.Update(func(cfg *config.Structure) error {
+		cfg.Packages = append(cfg.Packages, "htop")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to update config file: %s", err)
+	}
+
+	var written map[string]interface{}
+	data, _ := os.ReadFile(".develbox/config.json")
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("Failed to parse the written config: %s", err)
+	}
+	if !reflect.DeepEqual(written["packages"], []interface{}{"vim", "htop"}) || written["image"] != nil {
+		t.Errorf("Expected only the own packages to be written, got:\n%s", data)
+	}
+
+	// The downloaded preset is cached, it's used when the server can't be reached
+	if _, err := os.Stat(config.CachePath(server.URL + "/distro/base.json")); err != nil {
+		t.Errorf("Expected the preset to be cached: %s", err)
+	}
+	server.Close()
+	ttl := config.ExtendsCacheTTL
+	config.ExtendsCacheTTL = 0
+	defer func() { config.ExtendsCacheTTL = ttl }()
+	cfg, err = config.Read()
+	if err != nil {
+		t.Fatalf("Expected the cached preset to be used offline: %s", err)
+	}
+	if !pkgm.ContainsPackage(cfg.Packages, "curl") {
+		t.Errorf("Expected the packages of the cached preset, got %v", cfg.Packages)
+	}
+
+	os.WriteFile(".develbox/base.yaml", []byte("extends: ./config.json\n"), 0644)
+	if _, err := config.Read(); err == nil {
+		t.Errorf("Expected an error for configs that extend each other")
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+)
+
+// TestYAMLConfig tests that a YAML config is found, decoded and written back as YAML
+func TestYAMLConfig(t *testing.T) {
+	Setup(false, false)
+
+	original := `# Environment of the project
+image:
+  uri: alpine:latest
+  pkgmanager:
+    driver: apk
+container:
+  name: develbox-yaml
+commands:
+  build: go build ./... # compiles everything
+  check: [go vet ./..., go test ./...]
+packages:
+  - git
+`
+	if err := os.WriteFile(".develbox/config.yaml", []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+
+	if path := config.Path(); path != ".develbox/config.yaml" {
+		t.Fatalf("Expected .develbox/config.yaml to be found, got %s", path)
+	}
+	cfg, err := config.Read()
+	if err != nil {
+		t.Fatalf("Failed to read config file: %s", err)
+	}
+	if cfg.Container.Name != "develbox-yaml" || cfg.Commands["build"] != "go build ./..." {
+		t.Errorf("Expected the YAML values, got %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.Commands["check"], []interface{}{"go vet ./...", "go test ./..."}) {
+		t.Errorf("Expected the list of commands to be decoded like JSON ones, got %#v", cfg.Commands["check"])
+	}
+
+	_, err = config.Update(func(cfg *config.Structure) error {
+		cfg.Packages = append(cfg.Packages, "vim")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to update config file: %s", err)
+	}
+
+	written, _ := os.ReadFile(".develbox/config.yaml")
+	for _, expected := range []string{"# Environment of the project", "# compiles everything", "  - vim\n"} {
+		if !strings.Contains(string(written), expected) {
+			t.Errorf("Expected '%s' on the YAML config, got:\n%s", expected, written)
+		}
+	}
+	if config.FileExists(".develbox/config.json") {
+		t.Errorf("Expected the config to stay in YAML")
+	}
+}
+
+// TestTOMLConfig tests that a TOML config is decoded and written back as TOML
+func TestTOMLConfig(t *testing.T) {
+	Setup(false, false)
+
+	original := `packages = ["git"]
+
+[image]
+uri = "alpine:latest"
+
+[image.pkgmanager]
+driver = "apk"
+
+[container]
+name = "develbox-toml"
+`
+	if err := os.WriteFile(".develbox/config.toml", []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+
+	_, err := config.Update(func(cfg *config.Structure) error {
+		cfg.Packages = append(cfg.Packages, "vim")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to update config file: %s", err)
+	}
+
+	cfg, wasV1, err := config.ReadFile(".develbox/config.toml")
+	if err != nil || wasV1 {
+		t.Fatalf("Failed to read the TOML config: %s", err)
+	}
+	if cfg.Container.Name != "develbox-toml" || cfg.Image.PkgManager.Driver != "apk" || !reflect.DeepEqual(cfg.Packages, []string{"git", "vim"}) {
+		t.Errorf("Expected the TOML values to be kept, got %+v", cfg)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+)
+
+// TestConfigLayers tests that the global and local configs are merged with the project one,
+// and that the changes are written to the file they come from
+func TestConfigLayers(t *testing.T) {
+	Setup(false, false)
+	defer os.Remove(config.LocalPath())
+
+	globalDir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "develbox")
+	os.MkdirAll(globalDir, 0755)
+	defer os.RemoveAll(globalDir)
+
+	files := map[string]string{
+		filepath.Join(globalDir, "config.yaml"): "container:\n  shell: /bin/zsh\n",
+		".develbox/config.json":                 "{\n\t\"image\": {\"uri\": \"alpine:latest\", \"pkgmanager\": {\"driver\": \"apk\"}},\n\t\"container\": {\"name\": \"develbox-layers\"},\n\t\"packages\": [\"git\"]\n}\n",
+		".develbox/config.local.json":           "{\n\t\"container\": {\"mounts\": [\"/tmp:/tmp\"]},\n\t\"packages\": [\"gdb\"]\n}\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %s", path, err)
+		}
+	}
+
+	if config.GlobalPath() != filepath.Join(globalDir, "config.yaml") || config.LocalPath() != ".develbox/config.local.json" {
+		t.Fatalf("Expected the global and local configs to be found, got %s and %s", config.GlobalPath(), config.LocalPath())
+	}
+
+	cfg, err := config.Read()
+	if err != nil {
+		t.Fatalf("Failed to read config file: %s", err)
+	}
+	if cfg.Container.Shell != "/bin/zsh" || cfg.Container.Name != "develbox-layers" {
+		t.Errorf("Expected the global config to be merged, got %+v", cfg.Container)
+	}
+	if !reflect.DeepEqual(cfg.Packages, []string{"git", "gdb"}) || !reflect.DeepEqual(cfg.Container.Mounts, []string{"/tmp:/tmp"}) {
+		t.Errorf("Expected the local config to be merged, got %v and %v", cfg.Packages, cfg.Container.Mounts)
+	}
+
+	_, err = config.Update(func(cfg *config.Structure) error {
+		cfg.Packages = []string{"git", "vim"}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to update config file: %s", err)
+	}
+
+	written := map[string]map[string]interface{}{}
+	for _, path := range []string{".develbox/config.json", ".develbox/config.local.json"} {
+		var document map[string]interface{}
+		data, _ := os.ReadFile(path)
+		if err := json.Unmarshal(data, &document); err != nil {
+			t.Fatalf("Failed to parse %s: %s", path, err)
+		}
+		written[path] = document
+	}
+
+	project, local := written[".develbox/config.json"], written[".develbox/config.local.json"]
+	if !reflect.DeepEqual(project["packages"], []interface{}{"git", "vim"}) || project["container"].(map[string]interface{})["shell"] != nil {
+		t.Errorf("Expected only the new package to be written to the project config, got %v", project)
+	}
+	if !reflect.DeepEqual(local["packages"], []interface{}{}) || local["container"] == nil {
+		t.Errorf("Expected the package to be removed from the local config, got %v", local)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(globalDir, "config.yaml"))
+	if string(data) != files[filepath.Join(globalDir, "config.yaml")] {
+		t.Errorf("Expected the global config to stay the same, got:\n%s", data)
+	}
+}
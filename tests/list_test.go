@@ -0,0 +1,86 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kadmuffin/develbox/cmd"
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/container"
+	"github.com/kadmuffin/develbox/pkg/podman"
+)
+
+// TestList tests that stopped containers are listed with their project
+func TestList(t *testing.T) {
+	keepContainer = true
+	Setup(false, true)
+
+	pman := podman.New(podmanPath)
+	if err := pman.Stop([]string{testContainerName}, podman.Attach{}); err != nil {
+		t.Fatalf("Failed to stop container: %s", err)
+	}
+	defer pman.Start([]string{testContainerName}, podman.Attach{})
+
+	infos, err := container.List(pman)
+	if err != nil {
+		t.Fatalf("Failed to list containers: %s", err)
+	}
+
+	var found *container.Info
+	for i := range infos {
+		if infos[i].Name == testContainerName {
+			found = &infos[i]
+		}
+	}
+	if found == nil {
+		t.Fatalf("Container %s wasn't listed: %v", testContainerName, infos)
+	}
+
+	if found.Running || found.ProjectPath != config.GetCurrentDirectory() || !found.ProjectExists {
+		t.Errorf("Unexpected container info: %+v", *found)
+	}
+	if found.Image != SampleConfig.Image.URI || found.Version == "" || found.LastUsed.IsZero() {
+		t.Errorf("Missing image, version or last used time: %+v", *found)
+	}
+
+	var out bytes.Buffer
+	if err := cmd.PrintList(&out, []container.Info{*found}, "json"); err != nil {
+		t.Fatalf("Failed to print as JSON: %s", err)
+	}
+	parsed := []container.Info{}
+	if err := json.Unmarshal(out.Bytes(), &parsed); err != nil || len(parsed) != 1 || parsed[0].Name != testContainerName {
+		t.Errorf("Unexpected JSON output (%v): %s", err, out.String())
+	}
+
+	out.Reset()
+	if err := cmd.PrintList(&out, []container.Info{*found}, "{{.Name}}:{{.ProjectExists}}"); err != nil {
+		t.Fatalf("Failed to print using a template: %s", err)
+	}
+	if strings.TrimSpace(out.String()) != testContainerName+":true" {
+		t.Errorf("Unexpected template output: %s", out.String())
+	}
+
+	out.Reset()
+	if err := cmd.PrintList(&out, []container.Info{*found}, "table"); err != nil {
+		t.Fatalf("Failed to print as a table: %s", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[1], testContainerName) {
+		t.Errorf("Unexpected table output: %s", out.String())
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kadmuffin/develbox/pkg/container"
+	"github.com/kadmuffin/develbox/pkg/pkgm"
+)
+
+// TestParseVersions tests that the output of every query operation is understood
+func TestParseVersions(t *testing.T) {
+	pkgs := []string{"nodejs", "npm"}
+	expected := map[string]string{"nodejs": "18.12.1-r0", "npm": "9.1.2-r0"}
+
+	outputs := map[string]string{
+		"dpkg-query": "nodejs 18.12.1-r0\nnpm 9.1.2-r0\n",
+		"apk":        "nodejs-18.12.1-r0 x86_64 {nodejs} (MIT) [installed]\nnpm-9.1.2-r0 noarch {npm} (Artistic-2.0) [installed]\n",
+		"dnf":        "Installed Packages\nnodejs.x86_64   18.12.1-r0   @updates\nnpm.x86_64   9.1.2-r0   @updates\n",
+	}
+
+	for name, output := range outputs {
+		versions := pkgm.ParseVersions(output, pkgs)
+		if !reflect.DeepEqual(versions, expected) {
+			t.Errorf("Expected %v from the %s output, got %v", expected, name, versions)
+		}
+	}
+}
+
+// TestCreateLocked tests that "create --locked" installs the versions on the lock file
+func TestCreateLocked(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+	Setup(false, false)
+
+	cfg := SampleConfig
+	cfg.Image.PkgManager.Operations.Query = "apk list -I {args}"
+	cfg.Image.PkgManager.Modifiers = map[string]string{"pin": "{package}={version}"}
+
+	container.UseLockfile = true
+	if err := container.Create(cfg, true); err == nil {
+		t.Fatalf("Expected an error because the lock file doesn't exist")
+	}
+
+	err := pkgm.WriteLock(pkgm.Lock{Packages: map[string]string{"nodejs": "18.12.1-r0"}, UserPackages: map[string]string{}})
+	if err != nil {
+		t.Fatalf("Failed to write the lock file: %s", err)
+	}
+
+	engine.ClearCalls()
+	container.DontStopOnFinish = true
+	container.UseLockfile = true
+	if err := container.Create(cfg, true); err != nil {
+		t.Fatalf("Failed to create container: %s", err)
+	}
+
+	// The other packages aren't on the lock, so the latest versions are installed
+	pkgs := append(append([]string{}, cfg.Packages[1:]...), cfg.DevPackages...)
+	if !hasExec(t, "apk add nodejs=18.12.1-r0 "+strings.Join(pkgs, " ")) {
+		t.Errorf("Expected the locked version of nodejs to be installed")
+	}
+	if container.UseLockfile {
+		t.Errorf("Expected UseLockfile to be reset after creating the container")
+	}
+}
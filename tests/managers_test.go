@@ -0,0 +1,93 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/pkgm"
+)
+
+// TestPkgManagers tests that operations are routed to the package managers on image.pkgmanagers
+func TestPkgManagers(t *testing.T) {
+	cfg, _, err := config.ReadBytes([]byte(`{
+		"image": {
+			"pkgmanager": {"driver": "apk"},
+			"pkgmanagers": {
+				"pip": {"driver": "pip", "packages": ["flask"], "user": true}
+			}
+		},
+		"container": {"name": "develbox-managers"},
+		"packages": ["python3"]
+	}`))
+	if err != nil {
+		t.Fatalf("Failed to read the config: %s", err)
+	}
+
+	opert := pkgm.NewOperation("add", []string{"requests@2.31.0"}, []string{}, true)
+	if err := opert.SetManager(&cfg, "pip"); err != nil {
+		t.Fatalf("Failed to use pip: %s", err)
+	}
+	if !opert.UserOperation {
+		t.Errorf("Expected the pip operations to run as the user")
+	}
+
+	pip, _ := cfg.GetPkgManager("pip")
+	command, err := opert.StringCommand(&pip)
+	if err != nil || command != "pip install requests==2.31.0" {
+		t.Errorf("Expected 'pip install requests==2.31.0', got '%s' (%v)", command, err)
+	}
+
+	opert.UpdateConfig(&cfg)
+	if !reflect.DeepEqual(cfg.Image.PkgManagers["pip"].Packages, []string{"flask", "requests@2.31.0"}) {
+		t.Errorf("Expected requests to be added to pip, got %v", cfg.Image.PkgManagers["pip"].Packages)
+	}
+	if !reflect.DeepEqual(cfg.Packages, []string{"python3"}) || len(cfg.UserPkgs.Packages) != 0 {
+		t.Errorf("Expected the other lists to stay the same, got %v and %v", cfg.Packages, cfg.UserPkgs.Packages)
+	}
+
+	if err := opert.SetManager(&cfg, "cargo"); err == nil {
+		t.Errorf("Expected an error for a package manager that isn't on the config")
+	}
+	if err := opert.SetManager(&cfg, config.SystemPkgManager); err != nil || opert.Manager != "" {
+		t.Errorf("Expected 'system' to select the system package manager, got '%s' (%v)", opert.Manager, err)
+	}
+
+	if _, _, err := config.ReadBytes([]byte(`{"image": {"pkgmanagers": {"system": {"driver": "apt"}}}, "container": {"name": "x"}}`)); err == nil {
+		t.Errorf("Expected 'system' to be rejected as a package manager name")
+	}
+}
+
+// TestPkgManagerParsers tests the query parsers of the language package managers
+func TestPkgManagerParsers(t *testing.T) {
+	outputs := map[string]string{
+		"pip":   "Flask==2.3.2\nrequests==2.31.0\n",
+		"npm":   "/usr/local/lib\n├── @types/node@18.16.3\n└── requests@2.31.0\n",
+		"cargo": "requests v2.31.0:\n    requests\nripgrep v13.0.0:\n    rg\n",
+	}
+	for driverName, output := range outputs {
+		driver, _ := pkgm.GetDriver(&config.PackageManager{Driver: driverName})
+		versions := driver.Parse(output, []string{"requests", "@types/node"})
+		if versions["requests"] != "2.31.0" {
+			t.Errorf("[%s] Expected requests 2.31.0, got %v", driverName, versions)
+		}
+	}
+
+	if spec := pkgm.ParseSpec("@types/node@18"); spec.Name != "@types/node" || spec.Version != "18" || spec.Repo != "" {
+		t.Errorf("Expected the npm scope to be part of the name, got %+v", spec)
+	}
+}
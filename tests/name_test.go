@@ -0,0 +1,147 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/kadmuffin/develbox/cmd"
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/container"
+	"github.com/kadmuffin/develbox/pkg/podman"
+)
+
+// TestDefaultName tests that the default name is derived from the full path
+func TestDefaultName(t *testing.T) {
+	cfg := config.Structure{}
+	config.SetName(&cfg)
+
+	expected := "develbox-" + config.GetPathHash(config.GetCurrentDirectory())[:32]
+	if cfg.Container.Name != expected {
+		t.Errorf("Expected the default name %s, got %s", expected, cfg.Container.Name)
+	}
+	if cfg.Container.Name == config.LegacyName() {
+		t.Errorf("The default name is still derived from the folder name")
+	}
+}
+
+// createLabeled creates a container with a develbox_project_path label using the fake engine
+func createLabeled(t *testing.T, pman podman.Engine, name string, project string) {
+	args := []string{"--name", name, "--label", "develbox_container=1", "--label", fmt.Sprintf("develbox_project_path=\"%s\"", project), SampleConfig.Image.URI, "sh"}
+	if err := pman.Create(args, podman.Attach{}).Run(); err != nil {
+		t.Fatalf("Failed to create %s: %s", name, err)
+	}
+	t.Cleanup(func() { pman.Remove([]string{name}, podman.Attach{}) })
+}
+
+// TestMigrateName tests that containers named after the folder are renamed
+func TestMigrateName(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+	Setup(false, false)
+
+	pman := podman.New(podmanPath)
+	createLabeled(t, pman, config.LegacyName(), config.GetCurrentDirectory())
+
+	cfg := SampleConfig
+	cfg.Container.Name = ""
+	config.SetName(&cfg)
+	container.MigrateName(pman, cfg)
+
+	if pman.Exists(config.LegacyName()) || !pman.Exists(config.DefaultName()) {
+		t.Errorf("Container %s wasn't renamed to %s", config.LegacyName(), config.DefaultName())
+	}
+	pman.Remove([]string{config.DefaultName()}, podman.Attach{})
+}
+
+// TestMigrateNameOtherProject tests that the container of another folder with the same name isn't taken
+func TestMigrateNameOtherProject(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+	Setup(false, false)
+
+	pman := podman.New(podmanPath)
+	createLabeled(t, pman, config.LegacyName(), "/elsewhere/tests")
+
+	cfg := SampleConfig
+	cfg.Container.Name = config.DefaultName()
+	container.MigrateName(pman, cfg)
+
+	if !pman.Exists(config.LegacyName()) || pman.Exists(config.DefaultName()) {
+		t.Errorf("The container of another project was renamed")
+	}
+}
+
+// TestCreateCollision tests that create refuses to replace the container of another project
+func TestCreateCollision(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+	Setup(false, false)
+
+	pman := podman.New(podmanPath)
+	createLabeled(t, pman, "develbox-collision", "/elsewhere/project")
+
+	cfg := SampleConfig
+	cfg.Container.Name = "develbox-collision"
+	err := container.Create(cfg, true)
+
+	var collision *container.CollisionError
+	if !errors.As(err, &collision) || collision.ProjectPath != "/elsewhere/project" {
+		t.Fatalf("Expected a collision error, got: %v", err)
+	}
+
+	info, err := pman.Inspect("develbox-collision")
+	if err != nil || info.Label("develbox_project_path") != "/elsewhere/project" {
+		t.Errorf("The container of the other project was replaced")
+	}
+}
+
+// TestOpenCollision tests that the commands refuse to use the container of another project
+func TestOpenCollision(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+	Setup(false, false)
+	defer func() { config.Sets = []string{} }()
+
+	cfg := SampleConfig
+	if err := config.Write(&cfg); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+
+	pman := podman.New(podmanPath)
+	createLabeled(t, pman, "develbox-collision", "/elsewhere/project")
+	engine.ClearCalls()
+
+	err := cmd.ExecuteArgs([]string{"--set", "container.name=develbox-collision", "exec", "ls"})
+
+	var collision *container.CollisionError
+	if !errors.As(err, &collision) || collision.ProjectPath != "/elsewhere/project" {
+		t.Fatalf("Expected a collision error, got: %v", err)
+	}
+	if calls, _ := engine.Find("exec"); len(calls) > 0 {
+		t.Errorf("Expected nothing to run on the container of the other project, got %v", calls)
+	}
+
+	cfg.Container.Name = "develbox-missing"
+	if _, err := container.Open(pman, cfg); err != container.ErrNoContainer {
+		t.Errorf("Expected ErrNoContainer, got: %v", err)
+	}
+}
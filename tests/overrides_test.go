@@ -0,0 +1,79 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+)
+
+// TestOverrides tests that the environment variables and --set change the config without being written
+func TestOverrides(t *testing.T) {
+	Setup(false, false)
+	defer func() { config.Sets = []string{} }()
+
+	content := "{\n\t\"image\": {\"uri\": \"alpine:latest\", \"pkgmanager\": {\"driver\": \"apk\"}},\n\t\"container\": {\"name\": \"develbox-overrides\", \"ports\": [\"8080:80\"]},\n\t\"packages\": [\"git\"]\n}\n"
+	if err := os.WriteFile(".develbox/config.json", []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+
+	t.Setenv("DEVELBOX_CONTAINER_SHELL", "/bin/zsh")
+	t.Setenv("DEVELBOX_PODMAN_PRIVILEGED", "false")
+	t.Setenv("DEVELBOX_CONTAINER_PORTS", "3000:3000, 5000:5000")
+	config.Sets = []string{"container.shell=/bin/bash", "commands.build=make", "image.variables={\"CI\": \"true\"}"}
+
+	cfg, err := config.Read()
+	if err != nil {
+		t.Fatalf("Failed to read config file: %s", err)
+	}
+	if cfg.Container.Shell != "/bin/bash" || cfg.Podman.Privileged {
+		t.Errorf("Expected --set to win over the environment variables, got %+v", cfg.Container)
+	}
+	if !reflect.DeepEqual(cfg.Container.Ports, []string{"3000:3000", "5000:5000"}) {
+		t.Errorf("Expected the list to be replaced, got %v", cfg.Container.Ports)
+	}
+	if cfg.Commands["build"] != "make" || cfg.Image.Variables["CI"] != "true" {
+		t.Errorf("Expected the values of maps to be set, got %v and %v", cfg.Commands, cfg.Image.Variables)
+	}
+
+	_, err = config.Update(func(cfg *config.Structure) error {
+		cfg.Packages = append(cfg.Packages, "vim")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to update config file: %s", err)
+	}
+
+	var written map[string]interface{}
+	data, _ := os.ReadFile(".develbox/config.json")
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("Failed to parse the written config: %s", err)
+	}
+	container := written["container"].(map[string]interface{})
+	if !reflect.DeepEqual(written["packages"], []interface{}{"git", "vim"}) || container["shell"] != nil || written["commands"] != nil {
+		t.Errorf("Expected only the new package to be written, got:\n%s", data)
+	}
+
+	for _, set := range []string{"container.shel=/bin/bash", "podman.rootless=maybe", "container"} {
+		config.Sets = []string{set}
+		if _, err := config.Read(); err == nil {
+			t.Errorf("Expected an error for --set %s", set)
+		}
+	}
+}
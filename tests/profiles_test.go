@@ -0,0 +1,99 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+)
+
+// TestProfiles tests that the selected profile is merged over the config and gets its own container
+func TestProfiles(t *testing.T) {
+	Setup(false, false)
+	defer func() { config.ProfileName = "" }()
+
+	content := `{
+	"image": {"uri": "alpine:latest", "pkgmanager": {"driver": "apk"}},
+	"container": {"name": "develbox-profiles", "binds": {"xorg": false, "dev": false}},
+	"podman": {"privileged": false},
+	"packages": ["git"],
+	"devpackages": ["gdb", "vim"],
+	"profiles": {
+		"gui": {"container": {"binds": {"xorg": true, "dev": true}}, "podman": {"privileged": true}, "devpackages": ["gedit"]},
+		"minimal": {"devpackages": [], "container": {"name": "develbox-small"}}
+	}
+}
+`
+	if err := os.WriteFile(".develbox/config.json", []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+	if problems, err := config.Validate(".develbox/config.json"); err != nil || len(problems) > 0 {
+		t.Fatalf("Expected the profiles to be valid, got %v %v", problems, err)
+	}
+
+	cfg, err := config.Read()
+	if err != nil {
+		t.Fatalf("Failed to read config file: %s", err)
+	}
+	if cfg.Container.Name != "develbox-profiles" || cfg.Container.Binds.XOrg || len(cfg.Profiles) != 2 {
+		t.Errorf("Expected no profile to be used, got %+v", cfg.Container)
+	}
+
+	t.Setenv("DEVELBOX_PROFILE", "gui")
+	cfg, err = config.Read()
+	if err != nil {
+		t.Fatalf("Failed to read config file: %s", err)
+	}
+	if cfg.Container.Name != "develbox-profiles-gui" || !cfg.Container.Binds.XOrg || !cfg.Container.Binds.Dev || !cfg.Podman.Privileged {
+		t.Errorf("Expected the gui profile to be used, got %+v", cfg.Container)
+	}
+	if !reflect.DeepEqual(cfg.DevPackages, []string{"gedit"}) || !reflect.DeepEqual(cfg.Packages, []string{"git"}) {
+		t.Errorf("Expected the lists of the profile to replace the config ones, got %v and %v", cfg.DevPackages, cfg.Packages)
+	}
+
+	config.ProfileName = "minimal"
+	cfg, err = config.Read()
+	if err != nil {
+		t.Fatalf("Failed to read config file: %s", err)
+	}
+	if cfg.Container.Name != "develbox-small" || len(cfg.DevPackages) != 0 || cfg.Container.Binds.XOrg {
+		t.Errorf("Expected --profile to win over DEVELBOX_PROFILE, got %+v and %v", cfg.Container, cfg.DevPackages)
+	}
+
+	_, err = config.Update(func(cfg *config.Structure) error {
+		cfg.Packages = append(cfg.Packages, "htop")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to update config file: %s", err)
+	}
+	config.ProfileName = ""
+	t.Setenv("DEVELBOX_PROFILE", "")
+	cfg, err = config.Read()
+	if err != nil {
+		t.Fatalf("Failed to read config file: %s", err)
+	}
+	if cfg.Container.Name != "develbox-profiles" || !reflect.DeepEqual(cfg.Packages, []string{"git", "htop"}) || !reflect.DeepEqual(cfg.DevPackages, []string{"gdb", "vim"}) {
+		t.Errorf("Expected only the new package to be written, got %v, %v and %s", cfg.Packages, cfg.DevPackages, cfg.Container.Name)
+	}
+
+	config.ProfileName = "missing"
+	if _, err := config.Read(); err == nil {
+		t.Errorf("Expected an error for a profile that doesn't exist")
+	}
+}
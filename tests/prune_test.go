@@ -0,0 +1,130 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/container"
+	"github.com/kadmuffin/develbox/pkg/podman"
+)
+
+// TestPrune tests that only the leftovers of missing projects are pruned, and shared folders only when asked
+func TestPrune(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+	keepContainer = true
+	Setup(false, true)
+
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	shared := filepath.Join(dataHome, "develbox", "shared")
+	kept := []string{"used", "kept", "local", "global"}
+	for _, folder := range append([]string{"unused"}, kept...) {
+		os.MkdirAll(filepath.Join(shared, folder), 0755)
+		os.WriteFile(filepath.Join(shared, folder, "file"), make([]byte, 1000), 0644)
+	}
+
+	// kept isn't mounted by any container, but the config of the project uses it (auto_delete removes the container)
+	cfg := SampleConfig
+	cfg.Container.SharedFolders = map[string]interface{}{"kept": "/root/.cache"}
+	if err := config.Write(&cfg); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+
+	// local and global are only on the local and global configs of the project
+	layers := map[string]string{
+		config.LocalPath():  `{"container": {"shared_folders": {"local": "/root/.npm"}}}`,
+		config.GlobalPath(): `{"container": {"shared_folders": {"global": "/root/.m2"}}}`,
+	}
+	for path, data := range layers {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %s", path, err)
+		}
+		defer os.Remove(path)
+	}
+
+	pman := podman.New(podmanPath)
+	create := func(name string, project string, mounts ...string) {
+		args := []string{"--name", name, "--label", "develbox_container=1", "--label", fmt.Sprintf("develbox_project_path=\"%s\"", project)}
+		for _, mount := range mounts {
+			args = append(args, "-v="+mount+":/mnt:rw,z")
+		}
+		if err := pman.Create(append(args, SampleConfig.Image.URI, "sh"), podman.Attach{}).Run(); err != nil {
+			t.Fatalf("Failed to create %s: %s", name, err)
+		}
+		if err := pman.Commit([]string{name, name + "-image"}, podman.Attach{}).Run(); err != nil {
+			t.Fatalf("Failed to commit %s: %s", name, err)
+		}
+	}
+
+	create("develbox-live", config.GetCurrentDirectory(), filepath.Join(shared, "used", "data"))
+	defer pman.Remove([]string{"develbox-live"}, podman.Attach{})
+	create("develbox-orphan", "/nonexistent/project", filepath.Join(shared, "unused"))
+
+	orphans, err := container.FindOrphans(pman, false)
+	if err != nil {
+		t.Fatalf("Failed to search orphans: %s", err)
+	}
+	for _, orphan := range orphans {
+		if orphan.Kind == "shared" {
+			t.Errorf("Expected shared folders to be left out without --shared, got %+v", orphan)
+		}
+	}
+
+	orphans, err = container.FindOrphans(pman, true)
+	if err != nil {
+		t.Fatalf("Failed to search orphans: %s", err)
+	}
+
+	found := map[string]string{}
+	for _, orphan := range orphans {
+		found[orphan.Kind] = orphan.Ref
+	}
+	if len(orphans) != 3 || found["container"] != "develbox-orphan" || found["shared"] != filepath.Join(shared, "unused") || found["image"] == "" {
+		t.Fatalf("Unexpected orphans: %+v", orphans)
+	}
+
+	reclaimed, err := container.RemoveOrphans(pman, orphans)
+	if err != nil {
+		t.Fatalf("Failed to remove orphans: %s", err)
+	}
+	if reclaimed < 1000 {
+		t.Errorf("Expected at least 1000 bytes to be reclaimed, got %d", reclaimed)
+	}
+
+	if pman.Exists("develbox-orphan") || !pman.Exists("develbox-live") || !pman.Exists(testContainerName) {
+		t.Errorf("The wrong containers were removed")
+	}
+	if config.FileExists(filepath.Join(shared, "unused")) {
+		t.Errorf("The unused shared folder wasn't removed")
+	}
+	for _, folder := range kept {
+		if !config.FileExists(filepath.Join(shared, folder)) {
+			t.Errorf("The shared folder %s was removed", folder)
+		}
+	}
+
+	images, _ := pman.ListImages([]string{"develbox_container=1"})
+	if len(images) != 1 || images[0].RepoTags[0] != "develbox-live-image" {
+		t.Errorf("Unexpected images left: %+v", images)
+	}
+}
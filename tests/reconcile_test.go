@@ -0,0 +1,68 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kadmuffin/develbox/pkg/pkgm"
+)
+
+// TestReconcile tests that only the packages that changed on the config are added or removed
+func TestReconcile(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+	// A new container, so the state only has the packages of SampleConfig
+	Setup(false, true)
+	defer pkgm.Reconcile(&SampleConfig)
+
+	state, found := pkgm.ReadState(&SampleConfig)
+	expected := append(append([]string{}, SampleConfig.Packages...), SampleConfig.DevPackages...)
+	if !found || !reflect.DeepEqual(state.Packages, expected) {
+		t.Fatalf("Expected the state to have %v, got %v (found: %t)", expected, state.Packages, found)
+	}
+
+	cfg := SampleConfig
+	cfg.Packages = []string{"nodejs", "curl"}
+	engine.ClearCalls()
+
+	if err := pkgm.Reconcile(&cfg); err != nil {
+		t.Fatalf("Failed to reconcile: %s", err)
+	}
+	if !hasExec(t, "apk del npm") || !hasExec(t, "apk add curl") {
+		t.Errorf("Expected only npm to be removed and curl to be added")
+	}
+
+	state, _ = pkgm.ReadState(&cfg)
+	expected = append([]string{"nodejs", "curl"}, cfg.DevPackages...)
+	if !reflect.DeepEqual(state.Packages, expected) {
+		t.Errorf("Expected the state to have %v, got %v", expected, state.Packages)
+	}
+
+	// Nothing changed, so nothing should run
+	engine.ClearCalls()
+	if err := pkgm.Reconcile(&cfg); err != nil {
+		t.Fatalf("Failed to reconcile: %s", err)
+	}
+	execs, _ := engine.Find("exec")
+	for _, call := range execs {
+		if strings.HasPrefix(call.Args[len(call.Args)-1], "apk ") {
+			t.Errorf("Unexpected package operation: %s", call)
+		}
+	}
+}
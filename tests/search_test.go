@@ -0,0 +1,112 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kadmuffin/develbox/cmd/pkg"
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/pkgm"
+)
+
+// TestSearchParsers tests that the search output of every driver is parsed
+func TestSearchParsers(t *testing.T) {
+	outputs := map[string]string{
+		"apt":    "Sorting... Done\nFull Text Search... Done\nvim/stable 2:9.0.1378-2 amd64 [installed]\n  Vi IMproved - enhanced vi editor\n\n",
+		"pacman": "extra/vim 9.0.1000-1 [installed]\n    Vi Improved, a highly configurable, improved version of the vi text editor\n",
+		"zypper": "S | Name | Summary | Type\n--+------+---------+--------\ni | vim  | Vi IMproved | package\n",
+		"xbps":   "[*] vim-9.0.1000_1   Vim editor (vi clone)\n",
+	}
+
+	for driverName, output := range outputs {
+		driver, _ := pkgm.GetDriver(&config.PackageManager{Driver: driverName})
+		results := driver.ParseSearch(output)
+		if len(results) != 1 || results[0].Name != "vim" || !results[0].Installed || results[0].Description == "" {
+			t.Errorf("[%s] Expected vim to be found and installed, got %+v", driverName, results)
+		}
+	}
+
+	driver, _ := pkgm.GetDriver(&config.PackageManager{Driver: "nix"})
+	results := driver.ParseSearch("nixpkgs.vim  vim-9.0.1000  The most popular clone of the VI editor\n")
+	expected := []pkgm.SearchResult{{Name: "vim", Version: "9.0.1000", Description: "The most popular clone of the VI editor"}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, results)
+	}
+
+	driver, _ = pkgm.GetDriver(&config.PackageManager{Driver: "dnf"})
+	results = driver.ParseSearch("======== Name Exactly Matched: vim ========\nvim-enhanced.x86_64 : A version of the VIM editor which includes recent\n                    : enhancements\n")
+	if len(results) != 1 || results[0].Name != "vim-enhanced" || results[0].Description != "A version of the VIM editor which includes recent enhancements" {
+		t.Errorf("Expected vim-enhanced to be found, got %+v", results)
+	}
+}
+
+// TestSearch tests that the search results are marked as installed using the query operation
+func TestSearch(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+	Setup(false, false)
+
+	opert := pkgm.NewOperation("search", []string{"vim"}, []string{}, false)
+	if _, err := pkgm.Search(&SampleConfig, opert); !errors.Is(err, pkgm.ErrNoSearchParser) {
+		t.Errorf("Expected the custom driver to not parse the results, got %v", err)
+	}
+
+	cfg := SampleConfig
+	cfg.Image.PkgManager = config.PackageManager{Driver: "apk"}
+
+	// Both the search and the query operation print this
+	engine.Reply("exec", "vim-9.0.1000-r0 - Improved vi-style text editor\n", 0)
+	defer engine.Forget("exec")
+
+	results, err := pkgm.Search(&cfg, opert)
+	if err != nil {
+		t.Fatalf("Failed to search: %s", err)
+	}
+	expected := []pkgm.SearchResult{{Name: "vim", Version: "9.0.1000-r0", Description: "Improved vi-style text editor", Installed: true}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, results)
+	}
+
+	// pacman fails with 1 when nothing matches, other failures (like a stopped container) are errors
+	pacman := SampleConfig
+	pacman.Image.PkgManager = config.PackageManager{Driver: "pacman"}
+	engine.Reply("exec", "", 1)
+	if results, err := pkgm.Search(&pacman, opert); err != nil || len(results) != 0 {
+		t.Errorf("Expected no results and no error when nothing matches, got %v and %v", results, err)
+	}
+	engine.Reply("exec", "", 125)
+	if _, err := pkgm.Search(&pacman, opert); err == nil {
+		t.Errorf("Expected an error when the engine fails")
+	}
+	if _, err := pkgm.Search(&cfg, opert); err == nil {
+		t.Errorf("Expected an error when apk fails, it doesn't fail when nothing matches")
+	}
+	engine.Reply("exec", "vim-9.0.1000-r0 - Improved vi-style text editor\n", 0)
+
+	var out bytes.Buffer
+	if err := pkg.PrintSearch(&out, results, "json"); err != nil {
+		t.Fatalf("Failed to print the results: %s", err)
+	}
+	var printed []pkgm.SearchResult
+	if err := json.Unmarshal(out.Bytes(), &printed); err != nil || !reflect.DeepEqual(printed, expected) {
+		t.Errorf("Expected the JSON output to have %+v, got %s", expected, out.String())
+	}
+}
@@ -21,10 +21,12 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"testing"
 
 	"github.com/kadmuffin/develbox/cmd"
 	"github.com/kadmuffin/develbox/pkg/config"
 	"github.com/kadmuffin/develbox/pkg/container"
+	"github.com/kadmuffin/develbox/pkg/podman/fake"
 	"github.com/kpango/glg"
 )
 
@@ -38,7 +40,12 @@ var (
 	// The name of the test image
 	testImageName = "alpine:latest"
 
-	podmanPath = config.GetContainerTool()
+	// podmanPath is the engine used by the tests. It's the fake engine
+	// unless DEVELBOX_TEST_ENGINE is set (for example, to "podman" or "docker")
+	podmanPath string
+
+	// engine records the calls received by the fake engine, nil if the tests use a real engine
+	engine *fake.Recorder
 
 	setupAlreadyRun = false
 
@@ -111,8 +118,8 @@ func cleanTestEnv() error {
 	os.Remove(".develbox/config.json")
 	if !keepContainer {
 		os.RemoveAll(".develbox")
-		os.MkdirAll(".develbox/home", 0755)
 	}
+	os.MkdirAll(".develbox/home", 0755)
 
 	// Get the list of containers
 	out, err := exec.Command(podmanPath, "ps", "-a", "-q").Output()
@@ -155,7 +162,11 @@ func CopyConfig() {
 	}
 }
 
-func init() {
+// TestMain sets up the container engine before running the tests
+func TestMain(m *testing.M) {
+	// When the fake engine runs this binary, it never returns from here
+	fake.Main()
+
 	// Set the registry URL
 	registryURL = os.Getenv("REGISTRY_URL")
 	if registryURL == "" {
@@ -163,7 +174,21 @@ func init() {
 	}
 
 	// Set the container engine path
-	podmanPath = config.GetContainerTool()
+	var fakeDir string
+	podmanPath = os.Getenv("DEVELBOX_TEST_ENGINE")
+	if podmanPath == "" {
+		var err error
+		fakeDir, err = os.MkdirTemp("", "develbox-fake-engine")
+		if err != nil {
+			glg.Fatalf("Failed to create fake engine directory: %s", err)
+		}
+
+		engine, err = fake.New(fakeDir)
+		if err != nil {
+			glg.Fatalf("Failed to setup fake engine: %s", err)
+		}
+		podmanPath = engine.Path()
+	}
 
 	// Check if the container engine exists
 	_, err := exec.Command(podmanPath, "version").CombinedOutput()
@@ -176,4 +201,25 @@ func init() {
 	if err != nil {
 		glg.Fatalf("Failed to get container tool info: %s", err)
 	}
+
+	SampleConfig.Podman.Path = podmanPath
+	config.CheckDocker(&SampleConfig)
+
+	// The global config, the cache and the locks of the user shouldn't change the tests
+	globalDir, err := os.MkdirTemp("", "develbox-global-config")
+	if err != nil {
+		glg.Fatalf("Failed to create global config directory: %s", err)
+	}
+	os.Setenv("XDG_CONFIG_HOME", globalDir)
+	os.Setenv("XDG_CACHE_HOME", globalDir)
+	os.Setenv("XDG_RUNTIME_DIR", globalDir)
+
+	code := m.Run()
+	if fakeDir != "" {
+		os.RemoveAll(fakeDir)
+	}
+	os.RemoveAll(globalDir)
+	// The tests write the project files of tests/ (config, lock file, backups)
+	os.RemoveAll(".develbox")
+	os.Exit(code)
 }
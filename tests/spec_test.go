@@ -0,0 +1,104 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/pkgm"
+)
+
+// TestSpec tests that package specs are parsed and rendered for every package manager
+func TestSpec(t *testing.T) {
+	specs := map[string]pkgm.Spec{
+		"nodejs":         {Name: "nodejs"},
+		"nodejs@18":      {Name: "nodejs", Version: "18"},
+		"nodejs=18.*":    {Name: "nodejs", Version: "18.*"},
+		"edge/nodejs@18": {Name: "nodejs", Version: "18", Repo: "edge"},
+
+		// pip's version constraints
+		"requests==2.31.0": {Name: "requests", Version: "2.31.0"},
+		"numpy>=1.20":      {Name: "numpy", Version: "1.20", Operator: ">="},
+		"django~=4.2":      {Name: "django", Version: "4.2", Operator: "~="},
+		"flask!=2.0,<3":    {Name: "flask", Version: "2.0,<3", Operator: "!="},
+	}
+	for input, expected := range specs {
+		if spec := pkgm.ParseSpec(input); spec != expected {
+			t.Errorf("Expected '%s' to be %+v, got %+v", input, expected, spec)
+		}
+	}
+
+	tests := []struct {
+		driver   string
+		opert    pkgm.Operation
+		expected string
+	}{
+		{"apt", pkgm.NewOperation("add", []string{"nodejs@18.*"}, []string{}, false), "apt-get install 'nodejs=18.*'"},
+		{"dnf", pkgm.NewOperation("add", []string{"nodejs@18"}, []string{}, false), "dnf install nodejs-18"},
+		{"apk", pkgm.NewOperation("add", []string{"edge/nodejs@18"}, []string{}, false), "apk add nodejs=18@edge"},
+		{"pacman", pkgm.NewOperation("add", []string{"extra/vim"}, []string{}, false), "pacman -S extra/vim"},
+		{"apt", pkgm.NewOperation("del", []string{"nodejs@18"}, []string{}, false), "apt-get remove nodejs"},
+		{"pip", pkgm.NewOperation("add", []string{"requests==2.31.0"}, []string{}, false), "pip install requests==2.31.0"},
+		{"pip", pkgm.NewOperation("add", []string{"numpy>=1.20"}, []string{}, false), "pip install 'numpy>=1.20'"},
+		{"pip", pkgm.NewOperation("del", []string{"numpy>=1.20"}, []string{}, false), "pip uninstall -y numpy"},
+	}
+	for _, test := range tests {
+		command, err := test.opert.StringCommand(&config.PackageManager{Driver: test.driver})
+		if err != nil {
+			t.Fatalf("[%s] Failed to create the command: %s", test.driver, err)
+		}
+		if command != test.expected {
+			t.Errorf("[%s] Expected '%s', got '%s'", test.driver, test.expected, command)
+		}
+	}
+
+	opert := pkgm.NewOperation("add", []string{"vim@9"}, []string{}, false)
+	if _, err := opert.StringCommand(&config.PackageManager{Driver: "pacman"}); err == nil {
+		t.Errorf("Expected an error, pacman can't install a specific version")
+	}
+	opert = pkgm.NewOperation("add", []string{"nodejs>=18"}, []string{}, false)
+	if _, err := opert.StringCommand(&config.PackageManager{Driver: "apk"}); err == nil {
+		t.Errorf("Expected an error, apk doesn't understand version constraints")
+	}
+
+	pinned, _ := pkgm.PinPackages([]string{"requests==2.31.0", "numpy>=1.20"}, map[string]string{"requests": "2.31.0", "numpy": "1.26.4"})
+	if !reflect.DeepEqual(pinned, []string{"requests@2.31.0", "numpy@1.26.4"}) {
+		t.Errorf("Expected the locked versions to replace the constraints, got %v", pinned)
+	}
+}
+
+// TestSpecDuplicates tests that the config and the state compare packages by name
+func TestSpecDuplicates(t *testing.T) {
+	cfg := config.Structure{Packages: []string{"nodejs", "npm"}, DevPackages: []string{"git"}}
+
+	opert := pkgm.NewOperation("add", []string{"nodejs@18"}, []string{}, false)
+	opert.UpdateConfig(&cfg)
+	if !reflect.DeepEqual(cfg.Packages, []string{"npm", "nodejs@18"}) {
+		t.Errorf("Expected nodejs to be replaced by nodejs@18, got %v", cfg.Packages)
+	}
+
+	opert = pkgm.NewOperation("del", []string{"nodejs"}, []string{}, false)
+	opert.UpdateConfig(&cfg)
+	if !reflect.DeepEqual(cfg.Packages, []string{"npm"}) {
+		t.Errorf("Expected nodejs@18 to be removed, got %v", cfg.Packages)
+	}
+
+	add, del := pkgm.Diff([]string{"nodejs@18", "vim"}, []string{"nodejs@20"})
+	if !reflect.DeepEqual(add, []string{"nodejs@20"}) || !reflect.DeepEqual(del, []string{"vim"}) {
+		t.Errorf("Expected to install nodejs@20 and only remove vim, got add=%v del=%v", add, del)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"testing"
+
+	"github.com/kadmuffin/develbox/cmd/state"
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/podman"
+)
+
+// TestInspect tests that the container information is parsed from inspect
+func TestInspect(t *testing.T) {
+	keepContainer = true
+	Setup(false, true)
+
+	pman := podman.New(podmanPath)
+	info, err := pman.Inspect(testContainerName)
+	if err != nil {
+		t.Fatalf("Failed to inspect container: %s", err)
+	}
+
+	if info.Name != testContainerName || info.ID == "" {
+		t.Errorf("Unexpected name or ID: %s (%s)", info.Name, info.ID)
+	}
+	if !info.State.Running || info.State.Status != "running" {
+		t.Errorf("Expected a running container, got: %+v", info.State)
+	}
+	if info.Label("develbox_container") != "1" || info.Label("develbox_project_path") != config.GetCurrentDirectory() {
+		t.Errorf("Unexpected labels: %v", info.Config.Labels)
+	}
+
+	if _, err := pman.Inspect(testContainerName + "-missing"); err != podman.ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a missing container, got: %v", err)
+	}
+}
+
+// TestExistsExactName checks that Exists doesn't match containers whose name only starts with the name
+func TestExistsExactName(t *testing.T) {
+	keepContainer = true
+	Setup(false, true)
+
+	for _, kind := range []string{podman.KindPodman, podman.KindDocker, podman.KindNerdctl} {
+		if engine == nil && kind != podman.New(podmanPath).Kind() {
+			continue
+		}
+
+		pman := podman.NewEngine(kind, podmanPath)
+		if !pman.Exists(testContainerName) {
+			t.Errorf("%s: Exists() returned false for %s", kind, testContainerName)
+		}
+		if pman.Exists(testContainerName[:len(testContainerName)-1]) {
+			t.Errorf("%s: Exists() matched a prefix of %s", kind, testContainerName)
+		}
+	}
+}
+
+// TestSearchActiveContainer tests that the labels of the running containers are read
+func TestSearchActiveContainer(t *testing.T) {
+	keepContainer = true
+	Setup(false, true)
+
+	containers, err := state.SearchActiveContainer(podman.New(podmanPath))
+	if err != nil {
+		t.Fatalf("Failed to search active containers: %s", err)
+	}
+
+	for _, cont := range containers {
+		if cont.Name == testContainerName {
+			if cont.ProjectPath != config.GetCurrentDirectory() {
+				t.Errorf("Unexpected project path: %s", cont.ProjectPath)
+			}
+			return
+		}
+	}
+	t.Errorf("Container %s wasn't found on: %v", testContainerName, containers)
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kadmuffin/develbox/cmd/pkg"
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/pkgm"
+)
+
+// TestStatusParsers tests that the installed and outdated outputs of the drivers are parsed
+func TestStatusParsers(t *testing.T) {
+	outdated := map[string]string{
+		"apt":    "Listing... Done\nvim/stable 2:9.0.1378-2 amd64 [upgradable from: 2:9.0.1000-1]\n",
+		"dnf":    "\nLast metadata expiration check: 0:01:02 ago.\nvim.x86_64  2:9.0.1378-1.fc37  updates\n",
+		"apk":    "Installed:                                Available:\nvim-9.0.1000-r0                         < 9.0.1378-r0\n",
+		"pacman": "vim 9.0.1000-1 -> 9.0.1378-1\n",
+		"zypper": "S | Repository | Name | Current Version | Available Version | Arch\n--+------------+------+-----------------+-------------------+-------\nv | repo-oss   | vim  | 9.0.1000-1.1    | 9.0.1378-1.1      | x86_64\n",
+		"xbps":   "vim-9.0.1378_1 update x86_64 https://repo-default.voidlinux.org/current 1234 5678\n",
+		"nix":    "vim-9.0.1000  <  9.0.1378\n",
+	}
+	for driverName, output := range outdated {
+		driver, _ := pkgm.GetDriver(&config.PackageManager{Driver: driverName})
+		candidates := driver.ParseOutdatedOutput(output)
+		if len(candidates) != 1 || !strings.Contains(candidates["vim"], "9.0.1378") {
+			t.Errorf("[%s] Expected vim to have an update, got %v", driverName, candidates)
+		}
+	}
+
+	installed := map[string]string{
+		"apt":    "git\nvim\n",
+		"apk":    "git\nvim>=9.0\n",
+		"zypper": "S | Repository | Name | Version | Arch\n--+------------+------+---------+-------\ni | repo-oss   | git  | 2.39.0  | x86_64\ni | repo-oss   | vim  | 9.0.1   | x86_64\n",
+		"xbps":   "git-2.39.0_1\nvim-9.0.1000_1\n",
+		"nix":    "git-2.39.0\nvim-9.0.1000\n",
+	}
+	for driverName, output := range installed {
+		driver, _ := pkgm.GetDriver(&config.PackageManager{Driver: driverName})
+		pkgs := driver.ParseInstalledOutput(output)
+		if !reflect.DeepEqual(pkgs, []string{"git", "vim"}) {
+			t.Errorf("[%s] Expected git and vim to be installed, got %v", driverName, pkgs)
+		}
+	}
+}
+
+// TestStatus tests that the declared packages are compared with the installed ones
+func TestStatus(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+	Setup(false, false)
+
+	cfg := SampleConfig
+	cfg.Image.PkgManager.Operations.Query = "apk list -I {args}"
+	cfg.Image.PkgManager.Operations.Installed = "cat /etc/apk/world"
+
+	// Both the query and the installed operation print this
+	engine.Reply("exec", "nodejs 18.12.1-r0\ngit 2.38.1-r0\nvim 9.0.1000-r0\n", 0)
+	defer engine.Forget("exec")
+
+	statuses, err := pkgm.Status(&cfg)
+	if err != nil {
+		t.Fatalf("Failed to get the status of the packages: %s", err)
+	}
+	expected := []pkgm.PackageStatus{
+		{Name: "nodejs", Section: "packages", Installed: true, Version: "18.12.1-r0"},
+		{Name: "npm", Section: "packages"},
+		{Name: "git", Section: "devpackages", Installed: true, Version: "2.38.1-r0"},
+		{Name: "make", Section: "devpackages"},
+		{Name: "vim", Installed: true, Version: "9.0.1000-r0"},
+	}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, statuses)
+	}
+
+	var out bytes.Buffer
+	if err := pkg.PrintStatus(&out, statuses, "table"); err != nil {
+		t.Fatalf("Failed to print the packages: %s", err)
+	}
+	for _, state := range []string{"missing", "undeclared"} {
+		if !strings.Contains(out.String(), state) {
+			t.Errorf("Expected the table to show a %s package, got:\n%s", state, out.String())
+		}
+	}
+}
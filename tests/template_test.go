@@ -0,0 +1,89 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/pkgm/template"
+)
+
+// TestTemplate tests the template language of the operations and modifiers
+func TestTemplate(t *testing.T) {
+	vars := template.Vars{
+		"packages": template.Words([]string{"vim", "foo; rm -rf /", "it's"}),
+		"flags":    template.Words([]string{}),
+		"confirm":  template.Flag(true),
+		"user":     template.Flag(false),
+		"arch":     template.Word("x86_64"),
+	}
+
+	tests := map[string]string{
+		"apk add {packages}":                         `apk add vim 'foo; rm -rf /' 'it'\''s'`,
+		"apk add {packages|raw}":                     "apk add vim foo; rm -rf / it's",
+		"apt-get install {?confirm:-y} {packages}":   `apt-get install -y vim 'foo; rm -rf /' 'it'\''s'`,
+		"pip install {?user:--user}{!user:--system}": "pip install --system",
+		"nix-env -iA {packages:nixpkgs.{package}}":   `nix-env -iA nixpkgs.vim nixpkgs.'foo; rm -rf /' nixpkgs.'it'\''s'`,
+		"dnf install {-y} --forcearch={arch}":        "dnf install -y --forcearch=x86_64",
+		"dpkg-query -W -f='${Package}\\n' {{x}}":     "dpkg-query -W -f='${Package}\\n' {x}",
+	}
+
+	for source, expected := range tests {
+		tmpl, err := template.Parse(source)
+		if err != nil {
+			t.Fatalf("Failed to parse '%s': %s", source, err)
+		}
+		result, err := tmpl.Execute(vars)
+		if err != nil {
+			t.Fatalf("Failed to execute '%s': %s", source, err)
+		}
+		if result != expected {
+			t.Errorf("Expected '%s' to render as '%s', got '%s'", source, expected, result)
+		}
+	}
+
+	invalid := []string{
+		"apk add {pkgs}",
+		"apk add {packages",
+		"apk add {?confirm -y}",
+		"apk add {confirm}",
+		"apk add {arch:{item}}",
+		"apk add {packages|upper}",
+		"apk add }",
+	}
+	for _, source := range invalid {
+		if err := template.Validate(source, template.OperationSchema); err == nil {
+			t.Errorf("Expected '%s' to be invalid", source)
+		}
+	}
+}
+
+// TestInvalidTemplateConfig tests that the templates are validated when the config is read
+func TestInvalidTemplateConfig(t *testing.T) {
+	Setup(false, false)
+
+	cfg := SampleConfig
+	cfg.Image.PkgManager.Operations.Add = "apk add {pakages}"
+	if err := config.Write(&cfg); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+	defer os.Remove(".develbox/config.json")
+
+	if _, err := config.Read(); err == nil {
+		t.Errorf("Expected an error because of the unknown variable 'pakages'")
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+	"github.com/kadmuffin/develbox/pkg/pkgm"
+	"github.com/kadmuffin/develbox/pkg/podman"
+)
+
+// TestTransaction tests that the config is only changed when the package manager succeeds
+func TestTransaction(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+	keepContainer = true
+	Setup(false, true)
+
+	cfg := SampleConfig
+	cfg.Packages = append([]string{}, SampleConfig.Packages...)
+	if err := config.Write(&cfg); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+
+	engine.Reply("exec", "", 1)
+	opert := pkgm.NewOperation("add", []string{"vim"}, []string{}, true)
+	if err := opert.Apply(&cfg); err == nil {
+		t.Errorf("Expected the failed install to return an error")
+	}
+	engine.Forget("exec")
+
+	written, err := config.Read()
+	if err != nil {
+		t.Fatalf("Failed to read config file: %s", err)
+	}
+	if pkgm.ContainsPackage(written.Packages, "vim") || pkgm.ContainsPackage(cfg.Packages, "vim") {
+		t.Errorf("Expected vim to not be added after a failed install, got %v", written.Packages)
+	}
+
+	if err := opert.Apply(&cfg); err != nil {
+		t.Fatalf("Failed to install vim: %s", err)
+	}
+	written, err = config.Read()
+	if err != nil {
+		t.Fatalf("Failed to read config file: %s", err)
+	}
+	if !pkgm.ContainsPackage(written.Packages, "vim") {
+		t.Errorf("Expected vim to be added to the config, got %v", written.Packages)
+	}
+
+	temps, _ := filepath.Glob(".develbox/config.json.*")
+	if len(temps) > 0 {
+		t.Errorf("Expected the temporary files to be removed, found %v", temps)
+	}
+}
+
+// TestConfigUpdate tests that Update applies the change to the config on disk, not to an older copy
+func TestConfigUpdate(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+	keepContainer = true
+	Setup(false, true)
+
+	cfg := SampleConfig
+	cfg.Packages = append([]string{}, SampleConfig.Packages...)
+	if err := config.Write(&cfg); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+
+	// Another process adds a package after we read the config
+	other := SampleConfig
+	other.Packages = append(append([]string{}, SampleConfig.Packages...), "git")
+	if err := config.Write(&other); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+
+	opert := pkgm.NewOperation("add", []string{"vim"}, []string{}, true)
+	if err := opert.Apply(&cfg); err != nil {
+		t.Fatalf("Failed to install vim: %s", err)
+	}
+
+	written, err := config.Read()
+	if err != nil {
+		t.Fatalf("Failed to read config file: %s", err)
+	}
+	for _, pkg := range []string{"git", "vim"} {
+		if !pkgm.ContainsPackage(written.Packages, pkg) || !pkgm.ContainsPackage(cfg.Packages, pkg) {
+			t.Errorf("Expected %s on the config, got %v", pkg, written.Packages)
+		}
+	}
+}
+
+// TestLockFiles tests that the config and the transactions lock the files on .develbox/ and release them
+func TestLockFiles(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+	keepContainer = true
+	Setup(false, true)
+
+	cfg := SampleConfig
+	cfg.Packages = append([]string{}, SampleConfig.Packages...)
+	if err := config.Write(&cfg); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+	opert := pkgm.NewOperation("add", []string{"vim"}, []string{}, true)
+	if err := opert.Apply(&cfg); err != nil {
+		t.Fatalf("Failed to install vim: %s", err)
+	}
+
+	for _, path := range []string{config.ProjectLock, config.ConfigLock} {
+		if filepath.Dir(path) != ".develbox" || !config.FileExists(path) {
+			t.Errorf("Expected the lock %s to be created on .develbox", path)
+			continue
+		}
+		lock, err := config.Lock(path)
+		if err != nil {
+			t.Errorf("Expected %s to be released: %s", path, err)
+			continue
+		}
+		lock.Unlock()
+	}
+}
+
+// TestConfigBackup tests that converting a v1 config keeps a copy of the original
+func TestConfigBackup(t *testing.T) {
+	Setup(false, false)
+
+	original, err := os.ReadFile("config/alpine.v1.json")
+	if err != nil {
+		t.Fatalf("Failed to read the v1 config: %s", err)
+	}
+	if err := os.WriteFile(".develbox/config.json", original, 0644); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+	t.Cleanup(func() { os.Remove(".develbox/config.json.bak") })
+
+	if _, err := config.Read(); err != nil {
+		t.Fatalf("Failed to read config file: %s", err)
+	}
+
+	backup, err := os.ReadFile(".develbox/config.json.bak")
+	if err != nil || !bytes.Equal(backup, original) {
+		t.Errorf("Expected config.json.bak to be the v1 config (%v)", err)
+	}
+	if _, wasV1, err := config.ReadFile(".develbox/config.json"); err != nil || wasV1 {
+		t.Errorf("Expected config.json to be converted (%v)", err)
+	}
+}
+
+// TestCommit tests that a transaction run by the socket saves the config, the installed packages and the lock file
+func TestCommit(t *testing.T) {
+	if engine == nil {
+		t.Skip("Only supported by the fake engine")
+	}
+	Setup(false, true)
+	defer pkgm.Reconcile(&SampleConfig)
+
+	cfg := SampleConfig
+	cfg.Packages = append([]string{}, SampleConfig.Packages...)
+	cfg.Image.PkgManager.Operations.Query = "cat /versions"
+	if err := config.Write(&cfg); err != nil {
+		t.Fatalf("Failed to write config file: %s", err)
+	}
+	if err := pkgm.WriteState(&cfg, pkgm.State{Packages: append([]string{}, cfg.Packages...)}); err != nil {
+		t.Fatalf("Failed to write the state: %s", err)
+	}
+	if err := pkgm.WriteLock(pkgm.Lock{Packages: map[string]string{}, UserPackages: map[string]string{}}); err != nil {
+		t.Fatalf("Failed to write the lock file: %s", err)
+	}
+
+	// The socket runs the command itself, and commits the operation when it succeeds
+	write := podman.FromConfig(cfg.Podman).Exec([]string{cfg.Container.Name, "cat > /versions"}, map[string]string{}, true, true, podman.Attach{})
+	write.Stdin = strings.NewReader("vim 9.0.1000-r0\n")
+	if err := write.Run(); err != nil {
+		t.Fatalf("Failed to write the versions: %s", err)
+	}
+	opert := pkgm.NewOperation("add", []string{"vim"}, []string{}, true)
+	if err := opert.Commit(&cfg); err != nil {
+		t.Fatalf("Failed to commit the operation: %s", err)
+	}
+
+	written, err := config.Read()
+	if err != nil {
+		t.Fatalf("Failed to read config file: %s", err)
+	}
+	if !pkgm.ContainsPackage(written.Packages, "vim") {
+		t.Errorf("Expected vim to be added to the config, got %v", written.Packages)
+	}
+	state, _ := pkgm.ReadState(&cfg)
+	if !pkgm.ContainsPackage(state.Packages, "vim") {
+		t.Errorf("Expected vim to be saved as installed, got %v", state.Packages)
+	}
+	lock, err := pkgm.ReadLock()
+	if err != nil || lock.Packages["vim"] != "9.0.1000-r0" {
+		t.Errorf("Expected vim to be on the lock file, got %v (%v)", lock.Packages, err)
+	}
+}
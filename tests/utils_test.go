@@ -63,7 +63,7 @@ func PullImage() {
 func ContainerExists(name string) bool {
 	// Check if the container exists
 
-	if os.Getenv("GITHUB_ACTIONS") == "true" && !strings.Contains(podmanPath, "podman") {
+	if engine == nil && os.Getenv("GITHUB_ACTIONS") == "true" && !strings.Contains(podmanPath, "podman") {
 		// GitHub Actions doesn't support podman
 		// So we have to use docker
 		out, err := exec.Command("docker", "ps", "-a", "--format", "{{.Names}}").CombinedOutput()
@@ -76,10 +76,9 @@ func ContainerExists(name string) bool {
 
 	pman := podman.New(podmanPath)
 
-	switch pman.IsDocker() {
-	case true:
-		cmd := pman.RawCommand([]string{"inspect", name}, podman.Attach{})
-
+	switch pman.Kind() {
+	case podman.KindPodman:
+		cmd := pman.RawCommand([]string{"container", "exists", name}, podman.Attach{})
 		_, err := cmd.Output()
 		if err != nil {
 			glg.Errorf("Container %s does not exist", name)
@@ -87,8 +86,9 @@ func ContainerExists(name string) bool {
 			return false
 		}
 
-	case false:
-		cmd := pman.RawCommand([]string{"container", "exists", name}, podman.Attach{})
+	default:
+		cmd := pman.RawCommand([]string{"inspect", name}, podman.Attach{})
+
 		_, err := cmd.Output()
 		if err != nil {
 			glg.Errorf("Container %s does not exist", name)
@@ -0,0 +1,104 @@
+// Copyright 2022 Kevin Ledesma
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/kadmuffin/develbox/pkg/config"
+)
+
+// TestValidate tests that the problems of the config are found with their position
+func TestValidate(t *testing.T) {
+	document := `{
+	"x-team": "infra",
+	"image": {
+		"uri": "alpine:latest",
+		"pkgmanager": {
+			"operations": {"add": "apk add", "del": "apk del {args}"},
+			"modifiers": {"pin": "{package}"}
+		}
+	},
+	"container": {
+		"name": "develbox-validate",
+		"ports": ["8080:80", "127.0.0.1::3000/tcp", "70000:80"],
+		"mounts": ["/tmp:/tmp", "/tmp:ro"]
+	},
+	"commands": {"build": "go build", "check": 3},
+	"packages": "git",
+	"pakages": []
+}`
+
+	expected := map[string]int{
+		"image.pkgmanager.operations.add": 6,
+		"image.pkgmanager.modifiers.pin":  7,
+		"container.ports[2]":              12,
+		"container.mounts[1]":             13,
+		"commands.check":                  15,
+		"packages":                        16,
+		"pakages":                         17,
+	}
+
+	problems := config.ValidateBytes([]byte(document), "json")
+	found := map[string]int{}
+	for _, problem := range problems {
+		found[problem.Path] = problem.Line
+	}
+	for path, line := range expected {
+		if found[path] != line {
+			t.Errorf("Expected a problem on %s at line %d, got %v", path, line, problems)
+		}
+	}
+	if len(problems) != len(expected) {
+		t.Errorf("Expected %d problems, got %d: %v", len(expected), len(problems), problems)
+	}
+
+	problems = config.ValidateBytes([]byte("{\n\t\"image\": {\n\t\t\"uri\": \"alpine\",,\n\t}\n}"), "json")
+	if len(problems) != 1 || problems[0].Line != 3 || problems[0].Column != 19 {
+		t.Errorf("Expected a syntax error at 3:19, got %v", problems)
+	}
+
+	problems = config.ValidateBytes([]byte("container:\n  name: develbox\n  portz: []\n"), "yaml")
+	if len(problems) != 1 || problems[0].Path != "container.portz" || problems[0].Line != 3 || problems[0].Column != 3 {
+		t.Errorf("Expected the unknown key at 3:3, got %v", problems)
+	}
+
+	problems = config.ValidateBytes([]byte("image:\n  pkgmanager:\n    driver: aptt\n  pkgmanagers:\n    pip:\n      driver: pip\n"), "yaml")
+	if len(problems) != 1 || problems[0].Path != "image.pkgmanager.driver" || problems[0].Line != 3 || problems[0].Column != 5 {
+		t.Errorf("Expected the unknown driver at 3:5, got %v", problems)
+	}
+
+	if _, _, err := config.ReadBytes([]byte(`{"container": {"name": "x"}, "packages": "git"}`)); err == nil {
+		t.Errorf("Expected a config that can't be decoded to return an error")
+	}
+}
+
+// TestSchema tests that the published schema matches the config structure
+func TestSchema(t *testing.T) {
+	schema, err := config.SchemaJSON()
+	if err != nil {
+		t.Fatalf("Failed to generate the schema: %s", err)
+	}
+
+	published, err := os.ReadFile("../configs/schema.json")
+	if err != nil {
+		t.Fatalf("Failed to read configs/schema.json: %s", err)
+	}
+	if !bytes.Equal(schema, published) {
+		t.Errorf("configs/schema.json is outdated, run 'develbox config schema > configs/schema.json'")
+	}
+}